@@ -1,6 +1,10 @@
 package cache
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
 
 func TestBadgerCache_Has(t *testing.T) {
 	err := testBadgerCache.Forget("foo")
@@ -140,3 +144,52 @@ func TestBadgerCache_EmptyByMatch(t *testing.T) {
 		t.Error("beta not found in cache, and it should be there")
 	}
 }
+
+func TestBadgerCache_Prefix(t *testing.T) {
+	// a key written by something else sharing the database
+	err := testBadgerCache.Conn.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("shared"), []byte("not the cache's"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inCache, err := testBadgerCache.Has("shared")
+	if err != nil || inCache {
+		t.Errorf("expected a key outside the prefix not to be found, got %t %v", inCache, err)
+	}
+
+	err = testBadgerCache.Empty()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testBadgerCache.Conn.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte("shared"))
+		return err
+	})
+	if err != nil {
+		t.Errorf("expected Empty to leave keys outside the prefix, got %v", err)
+	}
+}
+
+func TestBadgerCache_SetError(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &BadgerCache{Conn: db}
+
+	err = c.Set("foo", "bar", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = db.Close()
+
+	err = c.Set("foo", "bar")
+	if err == nil {
+		t.Error("expected an error setting a value in a closed database")
+	}
+}
@@ -1,29 +1,64 @@
 package cache
 
 import (
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
+// BadgerCache keeps entries in a badger database. Keys are stored under Prefix, so that
+// the database can be shared with other data.
 type BadgerCache struct {
-	Conn *badger.DB
+	Conn   *badger.DB
 	Prefix string
+	Codec  Codec
+
+	stats counters
 }
 
+// key returns the key str is stored under
+func (b *BadgerCache) key(str string) []byte {
+	if b.Prefix == "" {
+		return []byte(str)
+	}
+	return []byte(b.Prefix + ":" + str)
+}
+
+// Has is counted in Stats as the lookup it makes with Get
 func (b *BadgerCache) Has(str string) (bool, error) {
 	_, err := b.Get(str)
-	if err != nil {
+	if errors.Is(err, ErrCacheMiss) {
 		return false, nil
 	}
+	if err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
 func (b *BadgerCache) Get(str string) (interface{}, error) {
+	var item interface{}
+	err := b.Scan(str, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Scan decodes the value stored under str into dest, which must be a pointer
+func (b *BadgerCache) Scan(str string, dest interface{}) (err error) {
+	defer b.stats.lookup(time.Now(), &err)
+
 	var fromCache []byte
 
-	err := b.Conn.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(str))
+	err = b.Conn.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key(str))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrCacheMiss
+		}
 		if err != nil {
 			return err
 		}
@@ -38,62 +73,95 @@ func (b *BadgerCache) Get(str string) (interface{}, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, err
-	}
-
-	decoded, err := decode(string(fromCache))
-	if err != nil {
-		return nil, err
+		return err
 	}
 
-	item := decoded[str]
-
-	return item, nil
+	return unmarshal(b.Codec, str, fromCache, dest)
 }
 
-func (b *BadgerCache) Set(str string, value interface{}, expires ...int) error {
-	entry := Entry{}
+func (b *BadgerCache) Set(str string, value interface{}, expires ...int) (err error) {
+	defer b.stats.write(time.Now(), &err)
 
-	entry[str] = value
-	encoded, err := encode(entry)
+	encoded, err := marshal(b.Codec, value)
 	if err != nil {
 		return err
 	}
 
-	if len(expires) > 0 {
-		err = b.Conn.Update(func(txn *badger.Txn) error {
-			e := badger.NewEntry([]byte(str), encoded).WithTTL(time.Second * time.Duration(expires[0]))
-			err = txn.SetEntry(e)
-			return err
-		})
-	} else {
-		err = b.Conn.Update(func(txn *badger.Txn) error {
-			e := badger.NewEntry([]byte(str), encoded)
-			err = txn.SetEntry(e)
-			return err
-		})
-	}
-
-	return nil
+	return b.Conn.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry(b.key(str), encoded)
+		if len(expires) > 0 {
+			e = e.WithTTL(time.Second * time.Duration(expires[0]))
+		}
+		return txn.SetEntry(e)
+	})
 }
 
-func (b *BadgerCache) Forget(str string) error {
-	err := b.Conn.Update(func(txn *badger.Txn) error {
-		err := txn.Delete([]byte(str))
+func (b *BadgerCache) Forget(str string) (err error) {
+	defer b.stats.write(time.Now(), &err)
+
+	err = b.Conn.Update(func(txn *badger.Txn) error {
+		err := txn.Delete(b.key(str))
 		return err
 	})
 
 	return err
 }
 
-func (b *BadgerCache) EmptyByMatch(str string) error {
+func (b *BadgerCache) EmptyByMatch(str string) (err error) {
+	defer b.stats.write(time.Now(), &err)
+
 	return b.emptyByMatch(str)
 }
 
-func (b *BadgerCache) Empty() error {
+// Empty removes every entry under b's prefix
+func (b *BadgerCache) Empty() (err error) {
+	defer b.stats.write(time.Now(), &err)
+
 	return b.emptyByMatch("")
 }
 
+// Tags returns the cache restricted to the given tags
+func (b *BadgerCache) Tags(names ...string) *TaggedCache {
+	return Tags(b, names...)
+}
+
+// Stats returns the lookups and writes made through b in this process
+func (b *BadgerCache) Stats() Stats {
+	return b.stats.stats()
+}
+
+// Info counts the entries under b's prefix, and returns the size of the database's files
+func (b *BadgerCache) Info() (Info, error) {
+	keys := 0
+
+	err := b.Conn.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := b.key("")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys++
+		}
+		return nil
+	})
+	if err != nil {
+		return Info{}, err
+	}
+
+	lsm, vlog := b.Conn.Size()
+
+	return Info{
+		Keys: keys,
+		Server: map[string]string{
+			"lsm_size":  strconv.FormatInt(lsm, 10),
+			"vlog_size": strconv.FormatInt(vlog, 10),
+		},
+	}, nil
+}
+
 func (b *BadgerCache) emptyByMatch(str string) error {
 	deleteKeys := func(keysForDelete [][]byte) error {
 		if err := b.Conn.Update(func(txn *badger.Txn) error {
@@ -111,7 +179,7 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 
 	collectSize := 100000
 
-	err := b.Conn.View(func(txn *badger.Txn) error{
+	err := b.Conn.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.AllVersions = false
 		opts.PrefetchValues = false
@@ -121,7 +189,8 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 		keysForDelete := make([][]byte, 0, collectSize)
 		keysCollected := 0
 
-		for it.Seek([]byte(str)); it.ValidForPrefix([]byte(str)); it.Next() {
+		prefix := b.key(str)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			key := it.Item().KeyCopy(nil)
 			keysForDelete = append(keysForDelete, key)
 			keysCollected++
@@ -129,6 +198,8 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 				if err := deleteKeys(keysForDelete); err != nil {
 					return err
 				}
+				keysForDelete = keysForDelete[:0]
+				keysCollected = 0
 			}
 		}
 
@@ -142,4 +213,187 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 	})
 
 	return err
-}
\ No newline at end of file
+}
+
+// update runs fn in a read-write transaction, and runs it again if it conflicted with
+// another transaction
+func (b *BadgerCache) update(fn func(txn *badger.Txn) error) error {
+	for {
+		err := b.Conn.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
+
+// getEntry reads the value stored under str in txn, with the time it expires at, in seconds
+// since the epoch, or 0 if it does not expire
+func (b *BadgerCache) getEntry(txn *badger.Txn, str string) (interface{}, uint64, error) {
+	item, err := txn.Get(b.key(str))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, 0, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fromCache, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var value interface{}
+	err = unmarshal(b.Codec, str, fromCache, &value)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return value, item.ExpiresAt(), nil
+}
+
+// setEntry stores value under str in txn, expiring after expires seconds, if given
+func (b *BadgerCache) setEntry(txn *badger.Txn, str string, value interface{}, expires ...int) error {
+	encoded, err := marshal(b.Codec, value)
+	if err != nil {
+		return err
+	}
+
+	e := badger.NewEntry(b.key(str), encoded)
+	if len(expires) > 0 {
+		e = e.WithTTL(time.Second * time.Duration(expires[0]))
+	}
+
+	return txn.SetEntry(e)
+}
+
+// Increment adds by to the integer stored under str, which starts from 0 if there is none,
+// and returns the result. The entry keeps its expiry.
+func (b *BadgerCache) Increment(str string, by int) (_ int, err error) {
+	defer b.stats.write(time.Now(), &err)
+
+	var result int
+
+	err = b.update(func(txn *badger.Txn) error {
+		current, expiresAt, err := b.getEntry(txn, str)
+		if errors.Is(err, ErrCacheMiss) {
+			current = 0
+		} else if err != nil {
+			return err
+		}
+
+		result, err = addInt(str, current, by)
+		if err != nil {
+			return err
+		}
+
+		encoded, err := marshal(b.Codec, result)
+		if err != nil {
+			return err
+		}
+
+		e := badger.NewEntry(b.key(str), encoded)
+		e.ExpiresAt = expiresAt
+		return txn.SetEntry(e)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// Decrement subtracts by from the integer stored under str, as Increment does
+func (b *BadgerCache) Decrement(str string, by int) (int, error) {
+	return b.Increment(str, -by)
+}
+
+// Add stores value under str, unless there is already an entry, and reports whether it did
+func (b *BadgerCache) Add(str string, value interface{}, expires ...int) (_ bool, err error) {
+	defer b.stats.write(time.Now(), &err)
+
+	added := false
+
+	err = b.update(func(txn *badger.Txn) error {
+		_, err := txn.Get(b.key(str))
+		if err == nil {
+			added = false
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		added = true
+		return b.setEntry(txn, str, value, expires...)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return added, nil
+}
+
+// Pull gets the value stored under str, and removes it from the cache
+func (b *BadgerCache) Pull(str string) (_ interface{}, err error) {
+	defer b.stats.lookup(time.Now(), &err)
+
+	var value interface{}
+
+	err = b.update(func(txn *badger.Txn) error {
+		var err error
+		value, _, err = b.getEntry(txn, str)
+		if err != nil {
+			return err
+		}
+
+		return txn.Delete(b.key(str))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Lock returns a lock called name, which expires after ttl seconds
+func (b *BadgerCache) Lock(name string, ttl int) *Lock {
+	return newLock(b, name, ttl)
+}
+
+func (b *BadgerCache) acquireLock(name, owner string, ttl int) (bool, error) {
+	if ttl > 0 {
+		return b.Add(lockKey(name), owner, ttl)
+	}
+	return b.Add(lockKey(name), owner)
+}
+
+func (b *BadgerCache) releaseLock(name, owner string) (bool, error) {
+	released := false
+
+	err := b.update(func(txn *badger.Txn) error {
+		held, _, err := b.getEntry(txn, lockKey(name))
+		if errors.Is(err, ErrCacheMiss) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if held != owner {
+			released = false
+			return nil
+		}
+
+		released = true
+		return txn.Delete(b.key(lockKey(name)))
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return released, nil
+}
+
+func (b *BadgerCache) forceReleaseLock(name string) error {
+	return b.Forget(lockKey(name))
+}
@@ -3,28 +3,46 @@ package cache
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
+// ErrCacheMiss is returned by Get when there is nothing in the cache under the key
+var ErrCacheMiss = errors.New("cache: key not found")
+
 type Cache interface {
 	Has(string) (bool, error)
 	Get(string) (interface{}, error)
+	Scan(string, interface{}) error
 	Set(string, interface{}, ...int) error
 	Forget(string) error
 	EmptyByMatch(string) error
 	Empty() error
+	Tags(...string) *TaggedCache
+	Increment(string, int) (int, error)
+	Decrement(string, int) (int, error)
+	Add(string, interface{}, ...int) (bool, error)
+	Pull(string) (interface{}, error)
+	Lock(string, int) *Lock
 }
 
 type RedisCache struct {
 	Conn   *redis.Pool
 	Prefix string
+	Codec  Codec
+
+	stats counters
 }
 
 type Entry map[string]interface{}
 
-func (c *RedisCache) Has(str string) (bool, error) {
+func (c *RedisCache) Has(str string) (found bool, err error) {
+	defer c.stats.exists(time.Now(), &found, &err)
+
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
@@ -60,33 +78,42 @@ func decode(str string) (Entry, error) {
 }
 
 func (c *RedisCache) Get(str string) (interface{}, error) {
+	var item interface{}
+	err := c.Scan(str, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Scan decodes the value stored under str into dest, which must be a pointer
+func (c *RedisCache) Scan(str string, dest interface{}) (err error) {
+	defer c.stats.lookup(time.Now(), &err)
+
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
 
 	cacheEntry, err := redis.Bytes(conn.Do("GET", key))
-	if err != nil {
-		return nil, err
+	if errors.Is(err, redis.ErrNil) {
+		return ErrCacheMiss
 	}
-
-	decoded, err := decode(string(cacheEntry))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	item := decoded[key]
-
-	return item, nil
+	return unmarshal(c.Codec, key, cacheEntry, dest)
 }
 
-func (c *RedisCache) Set(str string, value interface{}, expires ...int) error {
+func (c *RedisCache) Set(str string, value interface{}, expires ...int) (err error) {
+	defer c.stats.write(time.Now(), &err)
+
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
 
-	entry := Entry{}
-	entry[key] = value
-	encoded, err := encode(entry)
+	encoded, err := marshal(c.Codec, value)
 	if err != nil {
 		return err
 	}
@@ -106,12 +133,14 @@ func (c *RedisCache) Set(str string, value interface{}, expires ...int) error {
 	return nil
 }
 
-func (c *RedisCache) Forget(str string) error {
+func (c *RedisCache) Forget(str string) (err error) {
+	defer c.stats.write(time.Now(), &err)
+
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
 
-	_, err := conn.Do("DEL", key)
+	_, err = conn.Do("DEL", key)
 	if err != nil {
 		return err
 	}
@@ -119,7 +148,9 @@ func (c *RedisCache) Forget(str string) error {
 	return nil
 }
 
-func (c *RedisCache) EmptyByMatch(str string) error {
+func (c *RedisCache) EmptyByMatch(str string) (err error) {
+	defer c.stats.write(time.Now(), &err)
+
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
@@ -139,7 +170,9 @@ func (c *RedisCache) EmptyByMatch(str string) error {
 	return nil
 }
 
-func (c *RedisCache) Empty() error {
+func (c *RedisCache) Empty() (err error) {
+	defer c.stats.write(time.Now(), &err)
+
 	key := fmt.Sprintf("%s:", c.Prefix)
 	conn := c.Conn.Get()
 	defer conn.Close()
@@ -159,6 +192,54 @@ func (c *RedisCache) Empty() error {
 	return nil
 }
 
+// Tags returns the cache restricted to the given tags
+func (c *RedisCache) Tags(names ...string) *TaggedCache {
+	return Tags(c, names...)
+}
+
+// Stats returns the lookups and writes made through c in this process
+func (c *RedisCache) Stats() Stats {
+	return c.stats.stats()
+}
+
+// infoFields are the fields of the redis INFO command that Info returns
+var infoFields = []string{"redis_version", "uptime_in_days", "connected_clients", "used_memory_human",
+	"keyspace_hits", "keyspace_misses", "evicted_keys", "expired_keys"}
+
+// Info counts the entries under c's prefix, and returns what the server reports about its
+// memory use and the lookups it has answered for every client since it started
+func (c *RedisCache) Info() (Info, error) {
+	keys, err := c.getKeys(fmt.Sprintf("%s:", c.Prefix))
+	if err != nil {
+		return Info{}, err
+	}
+
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	reply, err := redis.String(conn.Do("INFO"))
+	if err != nil {
+		return Info{}, err
+	}
+
+	reported := make(map[string]string)
+	for _, line := range strings.Split(reply, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok {
+			reported[name] = value
+		}
+	}
+
+	server := make(map[string]string)
+	for _, name := range infoFields {
+		if value, ok := reported[name]; ok {
+			server[name] = value
+		}
+	}
+
+	return Info{Keys: len(keys), Server: server}, nil
+}
+
 func (c *RedisCache) getKeys(pattern string) ([]string, error) {
 	conn := c.Conn.Get()
 	defer conn.Close()
@@ -183,3 +264,184 @@ func (c *RedisCache) getKeys(pattern string) ([]string, error) {
 
 	return keys, nil
 }
+
+// Increment adds by to the integer stored under str, which starts from 0 if there is none,
+// and returns the result. The entry keeps its expiry. Redis runs it as a transaction, which
+// is retried if the entry changes before it completes.
+func (c *RedisCache) Increment(str string, by int) (_ int, err error) {
+	defer c.stats.write(time.Now(), &err)
+
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	// closing the connection also unwatches the key, if an error left it watched
+	defer conn.Close()
+
+	for {
+		_, err := conn.Do("WATCH", key)
+		if err != nil {
+			return 0, err
+		}
+
+		current := 0
+		cacheEntry, err := redis.Bytes(conn.Do("GET", key))
+		if err != nil && !errors.Is(err, redis.ErrNil) {
+			return 0, err
+		}
+		if err == nil {
+			var item interface{}
+			err = unmarshal(c.Codec, key, cacheEntry, &item)
+			if err != nil {
+				return 0, err
+			}
+			current, err = addInt(str, item, 0)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		ttl, err := redis.Int64(conn.Do("PTTL", key))
+		if err != nil {
+			return 0, err
+		}
+
+		encoded, err := marshal(c.Codec, current+by)
+		if err != nil {
+			return 0, err
+		}
+
+		_ = conn.Send("MULTI")
+		if ttl > 0 {
+			_ = conn.Send("SET", key, string(encoded), "PX", ttl)
+		} else {
+			_ = conn.Send("SET", key, string(encoded))
+		}
+		reply, err := conn.Do("EXEC")
+		if err != nil {
+			return 0, err
+		}
+
+		// a nil reply means the entry changed after WATCH, and nothing was written
+		if reply != nil {
+			return current + by, nil
+		}
+	}
+}
+
+// Decrement subtracts by from the integer stored under str, as Increment does
+func (c *RedisCache) Decrement(str string, by int) (int, error) {
+	return c.Increment(str, -by)
+}
+
+// Add stores value under str, unless there is already an entry, and reports whether it did
+func (c *RedisCache) Add(str string, value interface{}, expires ...int) (_ bool, err error) {
+	defer c.stats.write(time.Now(), &err)
+
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	encoded, err := marshal(c.Codec, value)
+	if err != nil {
+		return false, err
+	}
+
+	var reply interface{}
+	if len(expires) > 0 {
+		reply, err = conn.Do("SET", key, string(encoded), "NX", "EX", expires[0])
+	} else {
+		reply, err = conn.Do("SET", key, string(encoded), "NX")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return reply != nil, nil
+}
+
+// pullScript gets and deletes a key in one step, for servers without GETDEL
+var pullScript = redis.NewScript(1, `
+local value = redis.call("GET", KEYS[1])
+if value then
+	redis.call("DEL", KEYS[1])
+end
+return value
+`)
+
+// Pull gets the value stored under str, and removes it from the cache
+func (c *RedisCache) Pull(str string) (_ interface{}, err error) {
+	defer c.stats.lookup(time.Now(), &err)
+
+	key := fmt.Sprintf("%s:%s", c.Prefix, str)
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	cacheEntry, err := redis.Bytes(pullScript.Do(conn, key))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var item interface{}
+	err = unmarshal(c.Codec, key, cacheEntry, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Lock returns a lock called name, which expires after ttl seconds
+func (c *RedisCache) Lock(name string, ttl int) *Lock {
+	return newLock(c, name, ttl)
+}
+
+func (c *RedisCache) acquireLock(name, owner string, ttl int) (bool, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, lockKey(name))
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	var reply interface{}
+	var err error
+	if ttl > 0 {
+		reply, err = conn.Do("SET", key, owner, "NX", "EX", ttl)
+	} else {
+		reply, err = conn.Do("SET", key, owner, "NX")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return reply != nil, nil
+}
+
+// releaseScript deletes a lock only if it still belongs to the owner releasing it
+var releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (c *RedisCache) releaseLock(name, owner string) (bool, error) {
+	key := fmt.Sprintf("%s:%s", c.Prefix, lockKey(name))
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	released, err := redis.Int(releaseScript.Do(conn, key, owner))
+	if err != nil {
+		return false, err
+	}
+
+	return released == 1, nil
+}
+
+func (c *RedisCache) forceReleaseLock(name string) error {
+	key := fmt.Sprintf("%s:%s", c.Prefix, lockKey(name))
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", key)
+	return err
+}
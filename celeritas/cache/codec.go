@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/shamaton/msgpack/v2"
+)
+
+// Codec turns values into the bytes stored in a cache, and back. Every entry starts with the
+// header byte of the codec that wrote it, so entries written with one codec can still be read
+// after a driver switches to another. Entries written before codecs existed have no header,
+// and are read as gob.
+type Codec interface {
+	Header() byte
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Header bytes are above 0x7f, which the first byte of a gob stream holding a short type
+// definition, as every header-less entry starts with, never is.
+const (
+	gobHeader     byte = 0xc1
+	jsonHeader    byte = 0xc2
+	msgpackHeader byte = 0xc3
+	rawHeader     byte = 0xc4
+)
+
+var (
+	// GobCodec stores values with encoding/gob. Values keep their types, but types other
+	// than the built in ones must be registered with gob.Register. It is the default.
+	GobCodec Codec = gobCodec{}
+
+	// JSONCodec stores values as JSON, which other languages can read. Get returns what
+	// encoding/json decodes into an interface{}; GetAs decodes into the type asked for.
+	JSONCodec Codec = jsonCodec{}
+
+	// MsgpackCodec stores values as MessagePack: as portable as JSON, and smaller
+	MsgpackCodec Codec = msgpackCodec{}
+
+	// RawCodec stores strings and byte slices as they are, and integers as decimal text.
+	// Get returns a string.
+	RawCodec Codec = rawCodec{}
+)
+
+var codecs = map[byte]Codec{
+	gobHeader:     GobCodec,
+	jsonHeader:    JSONCodec,
+	msgpackHeader: MsgpackCodec,
+	rawHeader:     RawCodec,
+}
+
+// ParseCodec returns the codec called name, in any case: gob, json, msgpack or raw
+func ParseCodec(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "gob":
+		return GobCodec, nil
+	case "json":
+		return JSONCodec, nil
+	case "msgpack":
+		return MsgpackCodec, nil
+	case "raw":
+		return RawCodec, nil
+	}
+
+	return nil, fmt.Errorf("cache: unknown codec %q", name)
+}
+
+// marshal encodes value with codec, or with gob if codec is nil, behind the codec's header
+func marshal(codec Codec, value interface{}) ([]byte, error) {
+	if codec == nil {
+		codec = GobCodec
+	}
+
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{codec.Header()}, data...), nil
+}
+
+// unmarshal decodes the entry data, stored under key, into the pointer dest, with the codec
+// named by its header. Entries without a header hold a gob encoded Entry map.
+func unmarshal(codec Codec, key string, data []byte, dest interface{}) error {
+	if len(data) > 0 {
+		if c, ok := codecs[data[0]]; ok {
+			return c.Unmarshal(data[1:], dest)
+		}
+		if codec != nil && codec.Header() == data[0] {
+			return codec.Unmarshal(data[1:], dest)
+		}
+	}
+
+	decoded, err := decode(string(data))
+	if err != nil {
+		return err
+	}
+
+	return assign(key, decoded[key], dest)
+}
+
+// assign sets what the pointer dest points to to value, if value's type allows it
+func assign(key string, value interface{}, dest interface{}) error {
+	target := reflect.ValueOf(dest).Elem()
+
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(target.Type()) {
+		return fmt.Errorf("%w: %s holds a %T, not a %s", ErrWrongType, key, value, target.Type())
+	}
+
+	target.Set(v)
+	return nil
+}
+
+type gobCodec struct{}
+
+// gobValue wraps values, since gob only records the type of a value held in an interface
+type gobValue struct {
+	Value interface{}
+}
+
+func (gobCodec) Header() byte { return gobHeader }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	b := bytes.Buffer{}
+	err := gob.NewEncoder(&b).Encode(gobValue{Value: v})
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	var wrapped gobValue
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&wrapped)
+	if err != nil {
+		return err
+	}
+	return assign("value", wrapped.Value, v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Header() byte { return jsonHeader }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("%w: %s", ErrWrongType, err)
+	}
+
+	return err
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Header() byte { return msgpackHeader }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal reports every error as ErrWrongType: the entry was written by Marshal, and the
+// msgpack package does not tell a value of the wrong type apart from other failures
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	err := msgpack.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrWrongType, err)
+	}
+	return nil
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Header() byte { return rawHeader }
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case []byte:
+		return value, nil
+	case string:
+		return []byte(value), nil
+	case int:
+		return []byte(strconv.Itoa(value)), nil
+	case int64:
+		return []byte(strconv.FormatInt(value, 10)), nil
+	}
+
+	return nil, fmt.Errorf("%w: the raw codec stores strings, byte slices and integers, not a %T", ErrWrongType, v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch dest := v.(type) {
+	case *[]byte:
+		*dest = append([]byte{}, data...)
+	case *string:
+		*dest = string(data)
+	case *interface{}:
+		*dest = string(data)
+	case *int:
+		n, err := strconv.Atoi(string(data))
+		if err != nil {
+			return fmt.Errorf("%w: %q is not an integer", ErrWrongType, data)
+		}
+		*dest = n
+	default:
+		return fmt.Errorf("%w: the raw codec cannot decode into a %T", ErrWrongType, v)
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type codecUser struct {
+	ID    int
+	Name  string
+	Roles []string
+}
+
+func init() {
+	gob.Register(codecUser{})
+}
+
+// codecCaches returns each driver set to use codec
+func codecCaches(t testing.TB, codec Codec) map[string]Cache {
+	memory, err := NewMemoryCache(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory.Codec = codec
+	t.Cleanup(memory.Close)
+
+	return map[string]Cache{
+		"redis":  &RedisCache{Conn: testRedisCache.Conn, Prefix: "test-codec", Codec: codec},
+		"badger": &BadgerCache{Conn: testBadgerCache.Conn, Codec: codec},
+		"memory": memory,
+	}
+}
+
+var testCodecs = map[string]Codec{
+	"gob":     GobCodec,
+	"json":    JSONCodec,
+	"msgpack": MsgpackCodec,
+}
+
+func TestCodecs(t *testing.T) {
+	user := codecUser{ID: 5, Name: "Jack", Roles: []string{"admin", "editor"}}
+
+	for codecName, codec := range testCodecs {
+		for name, c := range codecCaches(t, codec) {
+			err := c.Set("codec-user", user)
+			if err != nil {
+				t.Fatal(codecName, name, err)
+			}
+
+			got, err := GetAs[codecUser](c, "codec-user")
+			if err != nil || got.Name != "Jack" || len(got.Roles) != 2 {
+				t.Errorf("%s %s: expected the user back, got %+v %v", codecName, name, got, err)
+			}
+
+			_, err = GetAs[int](c, "codec-user")
+			if !errors.Is(err, ErrWrongType) {
+				t.Errorf("%s %s: expected ErrWrongType, got %v", codecName, name, err)
+			}
+
+			_ = c.Forget("codec-counter")
+			_, _ = c.Increment("codec-counter", 2)
+			n, err := c.Increment("codec-counter", 3)
+			if err != nil || n != 5 {
+				t.Errorf("%s %s: expected the counter to reach 5, got %d %v", codecName, name, n, err)
+			}
+		}
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	for name, want := range map[string]Codec{"": GobCodec, "json": JSONCodec, "JSON": JSONCodec, "MsgPack": MsgpackCodec} {
+		codec, err := ParseCodec(name)
+		if err != nil || codec != want {
+			t.Errorf("%q: expected %v, got %v %v", name, want, codec, err)
+		}
+	}
+
+	if _, err := ParseCodec("protobuf"); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
+func TestCodec_Raw(t *testing.T) {
+	for name, c := range codecCaches(t, RawCodec) {
+		err := c.Set("codec-raw", "plain text")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		x, err := c.Get("codec-raw")
+		if err != nil || x != "plain text" {
+			t.Errorf("%s: expected plain text, got %v %v", name, x, err)
+		}
+
+		err = c.Set("codec-raw", codecUser{})
+		if !errors.Is(err, ErrWrongType) {
+			t.Errorf("%s: expected the raw codec to refuse a struct, got %v", name, err)
+		}
+
+		_ = c.Forget("codec-raw-counter")
+		n, err := c.Increment("codec-raw-counter", 7)
+		if err != nil || n != 7 {
+			t.Errorf("%s: expected 7, got %d %v", name, n, err)
+		}
+	}
+}
+
+func TestCodec_Readable(t *testing.T) {
+	c := &RedisCache{Conn: testRedisCache.Conn, Prefix: "test-codec", Codec: JSONCodec}
+
+	err := c.Set("readable", map[string]int{"answer": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := testRedisServer.Get("test-codec:readable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// after the header byte, the entry is plain JSON for other services to read
+	if stored[0] != jsonHeader || stored[1:] != `{"answer":42}` {
+		t.Errorf("unexpected entry %q", stored)
+	}
+}
+
+func TestCodec_LegacyEntries(t *testing.T) {
+	// entries written before codecs were added are a gob encoded Entry without a header
+	legacy, err := encode(Entry{"test-codec:legacy": "old value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = testRedisServer.Set("test-codec:legacy", string(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for codecName, codec := range testCodecs {
+		c := &RedisCache{Conn: testRedisCache.Conn, Prefix: "test-codec", Codec: codec}
+
+		x, err := GetAs[string](c, "legacy")
+		if err != nil || x != "old value" {
+			t.Errorf("%s: expected the legacy entry to be readable, got %v %v", codecName, x, err)
+		}
+	}
+
+	// and entries written with one codec stay readable after switching to another
+	_ = (&RedisCache{Conn: testRedisCache.Conn, Prefix: "test-codec", Codec: MsgpackCodec}).Set("switched", "value")
+	x, err := (&RedisCache{Conn: testRedisCache.Conn, Prefix: "test-codec", Codec: JSONCodec}).Get("switched")
+	if err != nil || x != "value" {
+		t.Errorf("expected an entry written with msgpack to be readable with json, got %v %v", x, err)
+	}
+}
+
+var benchmarkValue = codecUser{ID: 5, Name: "Jack", Roles: []string{"admin", "editor", strings.Repeat("x", 200)}}
+
+func BenchmarkCodec_Marshal(b *testing.B) {
+	for name, codec := range testCodecs {
+		b.Run(name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				data, err := marshal(codec, benchmarkValue)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/entry")
+		})
+	}
+}
+
+func BenchmarkCodec_Unmarshal(b *testing.B) {
+	for name, codec := range testCodecs {
+		data, err := marshal(codec, benchmarkValue)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var user codecUser
+				err := unmarshal(codec, "user", data, &user)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCodec_Raw(b *testing.B) {
+	value := strings.Repeat("x", 256)
+
+	for i := 0; i < b.N; i++ {
+		data, err := marshal(RawCodec, value)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var s string
+		err = unmarshal(RawCodec, "raw", data, &s)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodec_RedisRoundTrip(b *testing.B) {
+	for name, codec := range testCodecs {
+		c := &RedisCache{Conn: testRedisCache.Conn, Prefix: "bench", Codec: codec}
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				key := fmt.Sprintf("user-%d", i%100)
+				if err := c.Set(key, benchmarkValue); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := GetAs[codecUser](c, key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabaseCache_Prune(t *testing.T) {
+	err := testDatabaseCache.Empty()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = testDatabaseCache.Set("prune-kept", "kept")
+	_ = testDatabaseCache.Set("prune-expired", "expired", 1)
+
+	time.Sleep(2 * time.Second)
+
+	if inCache, _ := testDatabaseCache.Has("prune-expired"); inCache {
+		t.Error("expired entry found in cache")
+	}
+
+	pruned, err := testDatabaseCache.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("expected 1 entry to be pruned, got %d", pruned)
+	}
+
+	if inCache, _ := testDatabaseCache.Has("prune-kept"); !inCache {
+		t.Error("entry without an expiry was pruned")
+	}
+
+	// an expired entry can be added again before it is pruned
+	_ = testDatabaseCache.Set("prune-readd", "old", 1)
+	time.Sleep(2 * time.Second)
+
+	added, err := testDatabaseCache.Add("prune-readd", "new")
+	if err != nil || !added {
+		t.Errorf("expected to add over an expired entry, got %t %v", added, err)
+	}
+}
+
+func TestDatabaseCache_EmptyByMatch_Wildcards(t *testing.T) {
+	_ = testDatabaseCache.Set("100%_off", "matches")
+	_ = testDatabaseCache.Set("100x_off", "does not match")
+	_ = testDatabaseCache.Set("1000_off", "does not match")
+
+	err := testDatabaseCache.EmptyByMatch("100%_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inCache, _ := testDatabaseCache.Has("100%_off"); inCache {
+		t.Error("matching entry still in cache")
+	}
+
+	for _, key := range []string{"100x_off", "1000_off"} {
+		if inCache, _ := testDatabaseCache.Has(key); !inCache {
+			t.Errorf("%s was removed, but %% and _ should be matched literally", key)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tschenhau/celeritas/sqlbind"
+)
+
+// DatabaseCache keeps entries in the cache table of a postgres, mysql, mariadb or sqlite
+// database, so that every instance of an application can share a cache without redis. The
+// table is created by running `celeritas make cache-table`. Expiry times are stored as unix
+// seconds, with 0 for entries that do not expire; expired rows are ignored, and removed by
+// Prune.
+type DatabaseCache struct {
+	DB     *sql.DB
+	DBType string
+	Prefix string
+	Codec  Codec
+
+	stats counters
+}
+
+// rebind converts ? placeholders to $1, $2... for postgres
+func (d *DatabaseCache) rebind(query string) string {
+	return sqlbind.Rebind(d.DBType, query)
+}
+
+func (d *DatabaseCache) key(str string) string {
+	if d.Prefix == "" {
+		return str
+	}
+	return d.Prefix + ":" + str
+}
+
+// expiresAt returns the expiry time stored for an entry set now with expires
+func expiresAt(expires []int) int64 {
+	if len(expires) == 0 || expires[0] <= 0 {
+		return 0
+	}
+	return time.Now().Add(time.Duration(expires[0]) * time.Second).Unix()
+}
+
+// fetch returns the stored value of the entry under key, if it has not expired
+func (d *DatabaseCache) fetch(key string) ([]byte, error) {
+	var data []byte
+
+	err := d.DB.QueryRow(d.rebind(`select value from cache
+		where cache_key = ? and (expires_at = 0 or expires_at > ?)`), key, time.Now().Unix()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (d *DatabaseCache) Has(str string) (found bool, err error) {
+	defer d.stats.exists(time.Now(), &found, &err)
+
+	_, err = d.fetch(d.key(str))
+	if errors.Is(err, ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (d *DatabaseCache) Get(str string) (interface{}, error) {
+	var item interface{}
+	err := d.Scan(str, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Scan decodes the value stored under str into dest, which must be a pointer
+func (d *DatabaseCache) Scan(str string, dest interface{}) (err error) {
+	defer d.stats.lookup(time.Now(), &err)
+
+	key := d.key(str)
+
+	data, err := d.fetch(key)
+	if err != nil {
+		return err
+	}
+
+	return unmarshal(d.Codec, key, data, dest)
+}
+
+func (d *DatabaseCache) Set(str string, value interface{}, expires ...int) (err error) {
+	defer d.stats.write(time.Now(), &err)
+
+	encoded, err := marshal(d.Codec, value)
+	if err != nil {
+		return err
+	}
+
+	var upsert string
+	switch d.DBType {
+	case "mysql", "mariadb":
+		upsert = `insert into cache (cache_key, value, expires_at) values (?, ?, ?)
+			on duplicate key update value = values(value), expires_at = values(expires_at)`
+	default:
+		upsert = `insert into cache (cache_key, value, expires_at) values (?, ?, ?)
+			on conflict (cache_key) do update set value = excluded.value, expires_at = excluded.expires_at`
+	}
+
+	_, err = d.DB.Exec(d.rebind(upsert), d.key(str), encoded, expiresAt(expires))
+	return err
+}
+
+func (d *DatabaseCache) Forget(str string) (err error) {
+	defer d.stats.write(time.Now(), &err)
+
+	_, err = d.DB.Exec(d.rebind(`delete from cache where cache_key = ?`), d.key(str))
+	return err
+}
+
+// EmptyByMatch removes every entry whose key starts with str
+func (d *DatabaseCache) EmptyByMatch(str string) (err error) {
+	defer d.stats.write(time.Now(), &err)
+
+	return d.emptyByMatch(d.key(str))
+}
+
+// Empty removes every entry under the cache's prefix, or every entry if it has none
+func (d *DatabaseCache) Empty() (err error) {
+	defer d.stats.write(time.Now(), &err)
+
+	return d.emptyByMatch(d.key(""))
+}
+
+func (d *DatabaseCache) emptyByMatch(prefix string) error {
+	_, err := d.DB.Exec(d.rebind(`delete from cache where cache_key like ? escape '!'`), likePrefix(prefix))
+	return err
+}
+
+// likePrefix returns a like pattern matching the keys that start with prefix. ! escapes the
+// characters like treats as wildcards, the same way on every database.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
+
+// Prune removes the expired entries, and returns how many there were. Celeritas runs it
+// every hour when CACHE is database.
+func (d *DatabaseCache) Prune() (int64, error) {
+	res, err := d.DB.Exec(d.rebind(`delete from cache where expires_at <> 0 and expires_at <= ?`), time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Tags returns the cache restricted to the given tags
+func (d *DatabaseCache) Tags(names ...string) *TaggedCache {
+	return Tags(d, names...)
+}
+
+// Increment adds by to the integer stored under str, which starts from 0 if there is none,
+// and returns the result. The entry keeps its expiry. The new value is only written if the
+// entry has not changed since it was read; if it has, Increment reads it again.
+func (d *DatabaseCache) Increment(str string, by int) (_ int, err error) {
+	defer d.stats.write(time.Now(), &err)
+
+	key := d.key(str)
+
+	for {
+		data, err := d.fetch(key)
+		if errors.Is(err, ErrCacheMiss) {
+			added, err := d.add(key, by, 0)
+			if err != nil {
+				return 0, err
+			}
+			if added {
+				return by, nil
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var item interface{}
+		err = unmarshal(d.Codec, key, data, &item)
+		if err != nil {
+			return 0, err
+		}
+
+		result, err := addInt(str, item, by)
+		if err != nil {
+			return 0, err
+		}
+
+		// mysql reports an update that changes nothing as affecting no rows
+		if by == 0 {
+			return result, nil
+		}
+
+		encoded, err := marshal(d.Codec, result)
+		if err != nil {
+			return 0, err
+		}
+
+		res, err := d.DB.Exec(d.rebind(`update cache set value = ? where cache_key = ? and value = ?`),
+			encoded, key, data)
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n == 1 {
+			return result, nil
+		}
+	}
+}
+
+// Decrement subtracts by from the integer stored under str, as Increment does
+func (d *DatabaseCache) Decrement(str string, by int) (int, error) {
+	return d.Increment(str, -by)
+}
+
+// Add stores value under str, unless there is already an entry, and reports whether it did
+func (d *DatabaseCache) Add(str string, value interface{}, expires ...int) (_ bool, err error) {
+	defer d.stats.write(time.Now(), &err)
+
+	return d.add(d.key(str), value, expiresAt(expires))
+}
+
+// add inserts value under key, unless a row that has not expired is there already
+func (d *DatabaseCache) add(key string, value interface{}, expires int64) (bool, error) {
+	encoded, err := marshal(d.Codec, value)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = d.DB.Exec(d.rebind(`delete from cache where cache_key = ? and expires_at <> 0 and expires_at <= ?`),
+		key, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+
+	var insert string
+	switch d.DBType {
+	case "mysql", "mariadb":
+		insert = `insert ignore into cache (cache_key, value, expires_at) values (?, ?, ?)`
+	default:
+		insert = `insert into cache (cache_key, value, expires_at) values (?, ?, ?) on conflict (cache_key) do nothing`
+	}
+
+	res, err := d.DB.Exec(d.rebind(insert), key, encoded, expires)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// Pull gets the value stored under str, and removes it from the cache. The row is only
+// deleted if it still holds the value read, so that two callers never pull the same value.
+func (d *DatabaseCache) Pull(str string) (_ interface{}, err error) {
+	defer d.stats.lookup(time.Now(), &err)
+
+	key := d.key(str)
+
+	for {
+		data, err := d.fetch(key)
+		if err != nil {
+			return nil, err
+		}
+
+		deleted, err := d.deleteIf(key, data)
+		if err != nil {
+			return nil, err
+		}
+		if !deleted {
+			continue
+		}
+
+		var item interface{}
+		err = unmarshal(d.Codec, key, data, &item)
+		if err != nil {
+			return nil, err
+		}
+
+		return item, nil
+	}
+}
+
+// deleteIf deletes the row under key if it holds data, and reports whether it did
+func (d *DatabaseCache) deleteIf(key string, data []byte) (bool, error) {
+	res, err := d.DB.Exec(d.rebind(`delete from cache where cache_key = ? and value = ?`), key, data)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// Lock returns a lock called name, which expires after ttl seconds
+func (d *DatabaseCache) Lock(name string, ttl int) *Lock {
+	return newLock(d, name, ttl)
+}
+
+func (d *DatabaseCache) acquireLock(name, owner string, ttl int) (bool, error) {
+	return d.Add(lockKey(name), owner, ttl)
+}
+
+func (d *DatabaseCache) releaseLock(name, owner string) (bool, error) {
+	key := d.key(lockKey(name))
+
+	data, err := d.fetch(key)
+	if errors.Is(err, ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var holder string
+	err = unmarshal(d.Codec, key, data, &holder)
+	if err != nil || holder != owner {
+		return false, err
+	}
+
+	return d.deleteIf(key, data)
+}
+
+func (d *DatabaseCache) forceReleaseLock(name string) error {
+	return d.Forget(lockKey(name))
+}
+
+// Stats returns the lookups and writes made through d in this process
+func (d *DatabaseCache) Stats() Stats {
+	return d.stats.stats()
+}
+
+// Info counts the entries under d's prefix, and the expired rows waiting to be pruned
+func (d *DatabaseCache) Info() (Info, error) {
+	now := time.Now().Unix()
+
+	var keys, expired int
+	err := d.DB.QueryRow(d.rebind(`select count(*) from cache
+		where cache_key like ? escape '!' and (expires_at = 0 or expires_at > ?)`), likePrefix(d.key("")), now).Scan(&keys)
+	if err != nil {
+		return Info{}, err
+	}
+
+	err = d.DB.QueryRow(d.rebind(`select count(*) from cache where expires_at <> 0 and expires_at <= ?`),
+		now).Scan(&expired)
+	if err != nil {
+		return Info{}, err
+	}
+
+	return Info{
+		Keys: keys,
+		Server: map[string]string{
+			"database": d.DBType,
+			"expired":  strconv.Itoa(expired),
+		},
+	}, nil
+}
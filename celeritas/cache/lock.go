@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrLockTimeout is returned by Lock.Block when the lock could not be acquired in time
+var ErrLockTimeout = errors.New("cache: timed out waiting for lock")
+
+// lockRetry is how often Block tries to acquire a lock that is held
+var lockRetry = 100 * time.Millisecond
+
+// locker is implemented by each driver, which acquires and releases locks atomically
+type locker interface {
+	acquireLock(name, owner string, ttl int) (bool, error)
+	releaseLock(name, owner string) (bool, error)
+	forceReleaseLock(name string) error
+}
+
+// Lock is a lock held in a cache, and so shared by every instance of the application that
+// uses the same cache. Only the owner can release it; a lock that is never released expires
+// after TTL seconds, unless TTL is 0.
+//
+// To release a lock from somewhere else, such as a queued job, pass Owner along, and set it
+// on a Lock with the same name before calling Release.
+type Lock struct {
+	Name  string
+	Owner string
+	TTL   int
+
+	locker locker
+}
+
+func newLock(l locker, name string, ttl int) *Lock {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return &Lock{
+		Name:   name,
+		Owner:  hex.EncodeToString(b),
+		TTL:    ttl,
+		locker: l,
+	}
+}
+
+// Get tries to acquire the lock once, and reports whether it did
+func (l *Lock) Get() (bool, error) {
+	return l.locker.acquireLock(l.Name, l.Owner, l.TTL)
+}
+
+// Block waits up to timeout for the lock, and returns ErrLockTimeout if it is still held
+// by someone else
+func (l *Lock) Block(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		ok, err := l.Get()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return ErrLockTimeout
+		}
+		time.Sleep(min(lockRetry, remaining))
+	}
+}
+
+// Release releases the lock if it is still held by Owner, and reports whether it was
+func (l *Lock) Release() (bool, error) {
+	return l.locker.releaseLock(l.Name, l.Owner)
+}
+
+// ForceRelease releases the lock whoever holds it
+func (l *Lock) ForceRelease() error {
+	return l.locker.forceReleaseLock(l.Name)
+}
+
+func lockKey(name string) string {
+	return "lock:" + name
+}
+
+// addInt adds by to the counter value v, which must be an integer. Codecs other than gob do
+// not keep the type of a number, so whole floats, and with the raw codec, decimal strings,
+// are integers too.
+func addInt(key string, v interface{}, by int) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n + by, nil
+	case int8:
+		return int(n) + by, nil
+	case int16:
+		return int(n) + by, nil
+	case int32:
+		return int(n) + by, nil
+	case int64:
+		return int(n) + by, nil
+	case uint:
+		return int(n) + by, nil
+	case uint8:
+		return int(n) + by, nil
+	case uint16:
+		return int(n) + by, nil
+	case uint32:
+		return int(n) + by, nil
+	case uint64:
+		return int(n) + by, nil
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n) + by, nil
+		}
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i + by, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s holds a %T, not an integer", ErrWrongType, key, v)
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_Increment(t *testing.T) {
+	for name, c := range caches() {
+		_ = c.Forget("counter")
+
+		n, err := c.Increment("counter", 1)
+		if err != nil || n != 1 {
+			t.Errorf("%s: expected 1, got %d %v", name, n, err)
+		}
+
+		n, err = c.Decrement("counter", 3)
+		if err != nil || n != -2 {
+			t.Errorf("%s: expected -2, got %d %v", name, n, err)
+		}
+
+		// counters are ordinary entries
+		x, err := GetAs[int](c, "counter")
+		if err != nil || x != -2 {
+			t.Errorf("%s: expected to read -2, got %d %v", name, x, err)
+		}
+
+		_ = c.Set("counter", "not a number")
+		_, err = c.Increment("counter", 1)
+		if !errors.Is(err, ErrWrongType) {
+			t.Errorf("%s: expected ErrWrongType, got %v", name, err)
+		}
+	}
+}
+
+func TestCache_Increment_Concurrent(t *testing.T) {
+	for name, c := range caches() {
+		_ = c.Forget("hits")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 5; j++ {
+					if _, err := c.Increment("hits", 1); err != nil {
+						t.Error(name, err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		n, _ := GetAs[int](c, "hits")
+		if n != 100 {
+			t.Errorf("%s: expected 100 hits, got %d", name, n)
+		}
+	}
+}
+
+func TestCache_AddPull(t *testing.T) {
+	for name, c := range caches() {
+		_ = c.Forget("once")
+
+		added, err := c.Add("once", "first")
+		if err != nil || !added {
+			t.Errorf("%s: expected the first add to succeed, got %t %v", name, added, err)
+		}
+
+		added, err = c.Add("once", "second")
+		if err != nil || added {
+			t.Errorf("%s: expected the second add to fail, got %t %v", name, added, err)
+		}
+
+		x, err := c.Pull("once")
+		if err != nil || x != "first" {
+			t.Errorf("%s: expected to pull first, got %v %v", name, x, err)
+		}
+
+		_, err = c.Pull("once")
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: expected the entry to be gone after pulling it, got %v", name, err)
+		}
+	}
+}
+
+func TestCache_Lock(t *testing.T) {
+	for name, c := range caches() {
+		first := c.Lock("job", 10)
+		second := c.Lock("job", 10)
+		_ = first.ForceRelease()
+
+		ok, err := first.Get()
+		if err != nil || !ok {
+			t.Fatalf("%s: expected to acquire the lock, got %t %v", name, ok, err)
+		}
+
+		ok, err = second.Get()
+		if err != nil || ok {
+			t.Errorf("%s: lock acquired twice: %t %v", name, ok, err)
+		}
+
+		// only the owner can release the lock
+		released, err := second.Release()
+		if err != nil || released {
+			t.Errorf("%s: lock released by someone other than its owner: %t %v", name, released, err)
+		}
+
+		err = second.Block(150 * time.Millisecond)
+		if !errors.Is(err, ErrLockTimeout) {
+			t.Errorf("%s: expected ErrLockTimeout, got %v", name, err)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_, _ = first.Release()
+		}()
+
+		err = second.Block(2 * time.Second)
+		if err != nil {
+			t.Errorf("%s: expected to acquire the lock once released, got %v", name, err)
+		}
+
+		// a lock can be released from elsewhere by its owner
+		restored := c.Lock("job", 10)
+		restored.Owner = second.Owner
+		released, err = restored.Release()
+		if err != nil || !released {
+			t.Errorf("%s: expected the restored lock to be released, got %t %v", name, released, err)
+		}
+	}
+}
+
+// TestCache_Expiry checks that counters keep their expiry when they change, and that locks
+// expire, together, since badger expires entries to the second
+func TestCache_Expiry(t *testing.T) {
+	for name, c := range caches() {
+		_ = c.Set("expiring-counter", 1, 1)
+
+		_, err := c.Increment("expiring-counter", 1)
+		if err != nil {
+			t.Error(name, err)
+		}
+
+		lock := c.Lock("expiring", 1)
+		_ = lock.ForceRelease()
+
+		ok, _ := lock.Get()
+		if !ok {
+			t.Fatal(name, "expected to acquire the lock")
+		}
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+	testRedisServer.FastForward(3 * time.Second)
+
+	for name, c := range caches() {
+		if ok, _ := c.Has("expiring-counter"); ok {
+			t.Errorf("%s: counter lost its expiry when incremented", name)
+		}
+
+		ok, err := c.Lock("expiring", 1).Get()
+		if err != nil || !ok {
+			t.Errorf("%s: expected the lock to have expired, got %t %v", name, ok, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_Has(t *testing.T) {
+	_ = testMemoryCache.Forget("foo")
+
+	inCache, err := testMemoryCache.Has("foo")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if inCache {
+		t.Error("foo found in cache, and it shouldn't be there")
+	}
+
+	_ = testMemoryCache.Set("foo", "bar")
+	inCache, err = testMemoryCache.Has("foo")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !inCache {
+		t.Error("foo not found in cache")
+	}
+
+	_ = testMemoryCache.Forget("foo")
+}
+
+func TestMemoryCache_Get(t *testing.T) {
+	err := testMemoryCache.Set("foo", []string{"a"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	x, err := testMemoryCache.Get("foo")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// values are stored encoded, so changing what was read does not change the cache
+	x.([]string)[0] = "b"
+
+	x, _ = testMemoryCache.Get("foo")
+	if x.([]string)[0] != "a" {
+		t.Error("cached value was changed through a value read from the cache")
+	}
+}
+
+func TestMemoryCache_Expires(t *testing.T) {
+	err := testMemoryCache.Set("short", "lived", 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if ok, _ := testMemoryCache.Has("short"); !ok {
+		t.Error("short not found in cache")
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = testMemoryCache.Get("short")
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Error("expired entry still in cache:", err)
+	}
+}
+
+func TestMemoryCache_SizeLimit(t *testing.T) {
+	small, err := NewMemoryCache(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer small.Close()
+
+	err = small.Set("big", make([]byte, 4096))
+	if !errors.Is(err, ErrNotStored) {
+		t.Errorf("expected ErrNotStored, got %v", err)
+	}
+
+	if ok, _ := small.Has("big"); ok {
+		t.Error("entry larger than the cache was kept")
+	}
+
+	// an entry that was not stored is not added
+	added, err := small.Add("big", make([]byte, 4096))
+	if added || !errors.Is(err, ErrNotStored) {
+		t.Errorf("expected Add to fail with ErrNotStored, got %v, %v", added, err)
+	}
+}
+
+func TestMemoryCache_EmptyByMatch(t *testing.T) {
+	_ = testMemoryCache.Set("alpha", "foo")
+	_ = testMemoryCache.Set("alpha2", "foo")
+	_ = testMemoryCache.Set("beta", "foo")
+
+	err := testMemoryCache.EmptyByMatch("alpha")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if ok, _ := testMemoryCache.Has("alpha"); ok {
+		t.Error("alpha found in cache, and it should not be there")
+	}
+
+	if ok, _ := testMemoryCache.Has("alpha2"); ok {
+		t.Error("alpha2 found in cache, and it should not be there")
+	}
+
+	if ok, _ := testMemoryCache.Has("beta"); !ok {
+		t.Error("beta not found in cache, and it should be there")
+	}
+
+	err = testMemoryCache.Empty()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if ok, _ := testMemoryCache.Has("beta"); ok {
+		t.Error("beta found in cache after emptying it")
+	}
+}
@@ -0,0 +1,326 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+)
+
+// ErrNotStored is returned when the memory cache drops or rejects an entry, which ristretto
+// may do when the cache is contended or the entry costs more than the cache can hold
+var ErrNotStored = errors.New("cache: the entry was not stored")
+
+// MemoryCache keeps entries in process memory, in a ristretto cache bounded by size. Values
+// are stored encoded, as the other drivers store them, so a value read from the cache is a
+// copy, and an entry's cost is its encoded size in bytes.
+type MemoryCache struct {
+	Conn   *ristretto.Cache
+	Prefix string
+	Codec  Codec
+
+	// keys records what is in the cache, since ristretto only keeps hashes of its keys, so
+	// that EmptyByMatch can find matching entries. Entries remove themselves when evicted.
+	mu   sync.Mutex
+	keys map[string]struct{}
+
+	// writes makes Increment, Add, Pull and locks atomic with respect to other changes
+	writes sync.Mutex
+}
+
+// memoryEntry is what is stored in ristretto: the key is kept with the value so that it can
+// be dropped from keys when the entry is evicted
+type memoryEntry struct {
+	key  string
+	data []byte
+}
+
+// NewMemoryCache creates a MemoryCache that holds at most maxSize bytes of encoded values
+func NewMemoryCache(maxSize int64) (*MemoryCache, error) {
+	m := &MemoryCache{keys: make(map[string]struct{})}
+
+	conn, err := ristretto.NewCache(&ristretto.Config{
+		// ristretto recommends ten counters per item; assume items of around a kilobyte
+		NumCounters: max(maxSize/100, 100),
+		MaxCost:     maxSize,
+		BufferItems: 64,
+		OnEvict:     m.evicted,
+		OnReject:    m.evicted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.Conn = conn
+	return m, nil
+}
+
+// evicted is called by ristretto when an entry expires, or is evicted or rejected to stay
+// within the size limit
+func (m *MemoryCache) evicted(item *ristretto.Item) {
+	entry, ok := item.Value.(memoryEntry)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	delete(m.keys, entry.key)
+	m.mu.Unlock()
+}
+
+func (m *MemoryCache) key(str string) string {
+	if m.Prefix == "" {
+		return str
+	}
+	return m.Prefix + ":" + str
+}
+
+func (m *MemoryCache) Has(str string) (bool, error) {
+	_, ok := m.Conn.Get(m.key(str))
+	return ok, nil
+}
+
+func (m *MemoryCache) Get(str string) (interface{}, error) {
+	return m.get(m.key(str))
+}
+
+// Scan decodes the value stored under str into dest, which must be a pointer
+func (m *MemoryCache) Scan(str string, dest interface{}) error {
+	key := m.key(str)
+
+	fromCache, ok := m.Conn.Get(key)
+	if !ok {
+		return ErrCacheMiss
+	}
+
+	return unmarshal(m.Codec, key, fromCache.(memoryEntry).data, dest)
+}
+
+func (m *MemoryCache) get(key string) (interface{}, error) {
+	fromCache, ok := m.Conn.Get(key)
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	var item interface{}
+	err := unmarshal(m.Codec, key, fromCache.(memoryEntry).data, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (m *MemoryCache) Set(str string, value interface{}, expires ...int) error {
+	var ttl time.Duration
+	if len(expires) > 0 {
+		ttl = time.Second * time.Duration(expires[0])
+	}
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	return m.set(m.key(str), value, ttl)
+}
+
+func (m *MemoryCache) set(key string, value interface{}, ttl time.Duration) error {
+	encoded, err := marshal(m.Codec, value)
+	if err != nil {
+		return err
+	}
+
+	// sets are applied in the background; wait, so that the entry can be read straight away
+	m.Conn.SetWithTTL(key, memoryEntry{key: key, data: encoded}, int64(len(encoded)), ttl)
+	m.Conn.Wait()
+
+	// a set ristretto dropped leaves the previous entry, if any, in place
+	stored, ok := m.Conn.Get(key)
+	if !ok || !bytes.Equal(stored.(memoryEntry).data, encoded) {
+		return ErrNotStored
+	}
+
+	m.mu.Lock()
+	if m.keys == nil {
+		m.keys = make(map[string]struct{})
+	}
+	m.keys[key] = struct{}{}
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MemoryCache) Forget(str string) error {
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.forget(m.key(str))
+
+	return nil
+}
+
+func (m *MemoryCache) forget(key string) {
+	m.mu.Lock()
+	delete(m.keys, key)
+	m.mu.Unlock()
+
+	m.Conn.Del(key)
+}
+
+func (m *MemoryCache) EmptyByMatch(str string) error {
+	return m.emptyByMatch(m.key(str))
+}
+
+func (m *MemoryCache) Empty() error {
+	if m.Prefix == "" {
+		return m.emptyByMatch("")
+	}
+	return m.emptyByMatch(m.Prefix + ":")
+}
+
+// Tags returns the cache restricted to the given tags
+func (m *MemoryCache) Tags(names ...string) *TaggedCache {
+	return Tags(m, names...)
+}
+
+func (m *MemoryCache) emptyByMatch(prefix string) error {
+	var matched []string
+
+	m.mu.Lock()
+	for key := range m.keys {
+		if strings.HasPrefix(key, prefix) {
+			matched = append(matched, key)
+			delete(m.keys, key)
+		}
+	}
+	m.mu.Unlock()
+
+	// ristretto's eviction callbacks take the lock, so deletes are made without holding it
+	for _, key := range matched {
+		m.Conn.Del(key)
+	}
+
+	return nil
+}
+
+// Increment adds by to the integer stored under str, which starts from 0 if there is none,
+// and returns the result. The entry keeps its expiry.
+func (m *MemoryCache) Increment(str string, by int) (int, error) {
+	key := m.key(str)
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	current, err := m.get(key)
+	if errors.Is(err, ErrCacheMiss) {
+		current = 0
+	} else if err != nil {
+		return 0, err
+	}
+
+	result, err := addInt(str, current, by)
+	if err != nil {
+		return 0, err
+	}
+
+	ttl, _ := m.Conn.GetTTL(key)
+	err = m.set(key, result, ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// Decrement subtracts by from the integer stored under str, as Increment does
+func (m *MemoryCache) Decrement(str string, by int) (int, error) {
+	return m.Increment(str, -by)
+}
+
+// Add stores value under str, unless there is already an entry, and reports whether it did
+func (m *MemoryCache) Add(str string, value interface{}, expires ...int) (bool, error) {
+	key := m.key(str)
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	if _, ok := m.Conn.Get(key); ok {
+		return false, nil
+	}
+
+	var ttl time.Duration
+	if len(expires) > 0 {
+		ttl = time.Second * time.Duration(expires[0])
+	}
+
+	err := m.set(key, value, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Pull gets the value stored under str, and removes it from the cache
+func (m *MemoryCache) Pull(str string) (interface{}, error) {
+	key := m.key(str)
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	value, err := m.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	m.forget(key)
+
+	return value, nil
+}
+
+// Lock returns a lock called name, which expires after ttl seconds. Locks in a memory cache
+// are only shared within the process.
+func (m *MemoryCache) Lock(name string, ttl int) *Lock {
+	return newLock(m, name, ttl)
+}
+
+func (m *MemoryCache) acquireLock(name, owner string, ttl int) (bool, error) {
+	if ttl > 0 {
+		return m.Add(lockKey(name), owner, ttl)
+	}
+	return m.Add(lockKey(name), owner)
+}
+
+func (m *MemoryCache) releaseLock(name, owner string) (bool, error) {
+	key := m.key(lockKey(name))
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	held, err := m.get(key)
+	if errors.Is(err, ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if held != owner {
+		return false, nil
+	}
+
+	m.forget(key)
+
+	return true, nil
+}
+
+func (m *MemoryCache) forceReleaseLock(name string) error {
+	return m.Forget(lockKey(name))
+}
+
+// Close stops ristretto's background goroutines. The cache cannot be used afterwards.
+func (m *MemoryCache) Close() {
+	m.Conn.Close()
+}
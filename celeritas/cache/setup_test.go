@@ -1,18 +1,24 @@
 package cache
 
 import (
+	"database/sql"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/gomodule/redigo/redis"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 var testRedisCache RedisCache
 var testBadgerCache BadgerCache
+var testMemoryCache *MemoryCache
+var testDatabaseCache DatabaseCache
+var testRedisServer *miniredis.Miniredis
 
 func TestMain(m *testing.M) {
 	s, err := miniredis.Run()
@@ -20,6 +26,7 @@ func TestMain(m *testing.M) {
 		panic(err)
 	}
 	defer s.Close()
+	testRedisServer = s
 
 	pool := redis.Pool {
 		MaxIdle: 50,
@@ -51,6 +58,42 @@ func TestMain(m *testing.M) {
 
 	db, _ := badger.Open(badger.DefaultOptions("./testdata/tmp/badger"))
 	testBadgerCache.Conn = db
+	testBadgerCache.Prefix = "test-celeritas"
 
-	os.Exit(m.Run())
+	testMemoryCache, err = NewMemoryCache(1 << 20)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir, err := os.MkdirTemp("", "cache")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(dir, "cache.db")+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = sqlDB.Exec(`
+		create table cache (
+			cache_key text primary key,
+			value blob not null,
+			expires_at integer not null default 0
+		);
+		create index cache_expires_at_idx on cache (expires_at);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testDatabaseCache.DB = sqlDB
+	testDatabaseCache.DBType = "sqlite"
+	testDatabaseCache.Prefix = "test-celeritas"
+
+	code := m.Run()
+
+	_ = sqlDB.Close()
+	_ = os.RemoveAll(dir)
+
+	os.Exit(code)
 }
\ No newline at end of file
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts what a cache driver has done since it was created, in this process. Lookups
+// are calls to Has, Get, Scan and Pull; each one is a hit, a miss or an error. Writes are
+// calls to Set, Forget, Add, Increment, Decrement, EmptyByMatch and Empty.
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Writes  uint64
+	Errors  uint64
+	Latency time.Duration // the time spent in every lookup and write
+}
+
+// Calls returns the number of lookups and writes
+func (s Stats) Calls() uint64 {
+	return s.Hits + s.Misses + s.Writes + s.Errors
+}
+
+// HitRatio returns the share of lookups that found an entry, from 0 to 1
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// AverageLatency returns the mean time a lookup or write took
+func (s Stats) AverageLatency() time.Duration {
+	calls := s.Calls()
+	if calls == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(calls)
+}
+
+// StatsKey is the key under which PublishStats keeps the stats of every instance of an
+// application, since Stats only counts what happens in one process
+const StatsKey = "celeritas-stats"
+
+// InstanceStats are the stats an instance of an application published, and when
+type InstanceStats struct {
+	Stats
+	Published time.Time
+}
+
+// PublishStats stores stats in c, under StatsKey, as those of instance, next to the stats the
+// other instances published; `celeritas cache:stats` reads them with ReadStats. Instances
+// that have not published for maxAge are dropped. A lock stops instances publishing at the
+// same moment from overwriting each other.
+func PublishStats(c Cache, instance string, stats Stats, maxAge time.Duration) error {
+	lock := c.Lock(StatsKey, 10)
+	err := lock.Block(5 * time.Second)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = lock.Release()
+	}()
+
+	published, err := ReadStats(c)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for name, s := range published {
+		if now.Sub(s.Published) > maxAge {
+			delete(published, name)
+		}
+	}
+	published[instance] = InstanceStats{Stats: stats, Published: now}
+
+	// kept as a json string, which every codec can store
+	b, err := json.Marshal(published)
+	if err != nil {
+		return err
+	}
+	return c.Set(StatsKey, string(b))
+}
+
+// ReadStats returns the stats published in c with PublishStats, by instance
+func ReadStats(c Cache) (map[string]InstanceStats, error) {
+	published := make(map[string]InstanceStats)
+
+	value, err := c.Get(StatsKey)
+	if errors.Is(err, ErrCacheMiss) {
+		return published, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("cache: %s holds a %T, not published stats", StatsKey, value)
+	}
+
+	err = json.Unmarshal([]byte(s), &published)
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// counters keeps the Stats of a driver. Its methods are deferred at the start of a call,
+// with the time the call started and pointers to its results.
+type counters struct {
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	writes  atomic.Uint64
+	errors  atomic.Uint64
+	latency atomic.Int64
+}
+
+func (c *counters) stats() Stats {
+	return Stats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Writes:  c.writes.Load(),
+		Errors:  c.errors.Load(),
+		Latency: time.Duration(c.latency.Load()),
+	}
+}
+
+// lookup records a lookup that returns ErrCacheMiss when there is no entry
+func (c *counters) lookup(start time.Time, err *error) {
+	c.latency.Add(int64(time.Since(start)))
+
+	switch {
+	case *err == nil:
+		c.hits.Add(1)
+	case errors.Is(*err, ErrCacheMiss):
+		c.misses.Add(1)
+	default:
+		c.errors.Add(1)
+	}
+}
+
+// exists records a lookup that reports whether there is an entry
+func (c *counters) exists(start time.Time, found *bool, err *error) {
+	if *err == nil && !*found {
+		miss := ErrCacheMiss
+		c.lookup(start, &miss)
+		return
+	}
+	c.lookup(start, err)
+}
+
+// write records a write
+func (c *counters) write(start time.Time, err *error) {
+	c.latency.Add(int64(time.Since(start)))
+
+	if *err != nil {
+		c.errors.Add(1)
+		return
+	}
+	c.writes.Add(1)
+}
+
+// Info describes what is in the store behind a cache, for every process using it
+type Info struct {
+	Keys   int               // the entries stored under the cache's prefix
+	Server map[string]string // what the store reports about itself, such as its memory use
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+type statsCache interface {
+	Cache
+	Stats() Stats
+	Info() (Info, error)
+}
+
+func TestCache_Stats(t *testing.T) {
+	drivers := map[string]statsCache{
+		"redis":    &testRedisCache,
+		"badger":   &testBadgerCache,
+		"database": &testDatabaseCache,
+	}
+
+	for name, c := range drivers {
+		before := c.Stats()
+
+		err := c.Set("stats", "counted")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, _ = c.Get("stats")
+		_, _ = c.Has("stats")
+		_, _ = c.Get("stats-never-set")
+
+		// a value of the wrong type is an error
+		var n int
+		_ = c.Scan("stats", &n)
+
+		after := c.Stats()
+
+		if hits := after.Hits - before.Hits; hits != 2 {
+			t.Errorf("%s: expected 2 hits, got %d", name, hits)
+		}
+		if misses := after.Misses - before.Misses; misses != 1 {
+			t.Errorf("%s: expected 1 miss, got %d", name, misses)
+		}
+		if writes := after.Writes - before.Writes; writes != 1 {
+			t.Errorf("%s: expected 1 write, got %d", name, writes)
+		}
+		if errs := after.Errors - before.Errors; errs != 1 {
+			t.Errorf("%s: expected 1 error, got %d", name, errs)
+		}
+		if after.Latency <= before.Latency || after.AverageLatency() <= 0 {
+			t.Errorf("%s: latency was not recorded", name)
+		}
+		if after.HitRatio() <= 0 || after.HitRatio() > 1 {
+			t.Errorf("%s: hit ratio out of range: %f", name, after.HitRatio())
+		}
+	}
+}
+
+func TestCache_Info(t *testing.T) {
+	drivers := map[string]statsCache{
+		"redis":    &testRedisCache,
+		"badger":   &testBadgerCache,
+		"database": &testDatabaseCache,
+	}
+
+	for name, c := range drivers {
+		err := c.Empty()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_ = c.Set("info-one", 1)
+		_ = c.Set("info-two", 2)
+
+		info, err := c.Info()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if info.Keys != 2 {
+			t.Errorf("%s: expected 2 keys, got %d", name, info.Keys)
+		}
+		if len(info.Server) == 0 {
+			t.Errorf("%s: expected the store to report about itself", name)
+		}
+	}
+}
+
+func TestStats_Empty(t *testing.T) {
+	var s Stats
+
+	if s.HitRatio() != 0 || s.AverageLatency() != 0 || s.Calls() != 0 {
+		t.Error("expected zero stats without any calls")
+	}
+}
+
+func TestPublishStats(t *testing.T) {
+	drivers := map[string]statsCache{
+		"redis":    &testRedisCache,
+		"badger":   &testBadgerCache,
+		"database": &testDatabaseCache,
+	}
+
+	for name, c := range drivers {
+		_ = c.Forget(StatsKey)
+
+		err := PublishStats(c, "web-1", Stats{Hits: 3, Misses: 1}, time.Minute)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		_ = PublishStats(c, "web-2", Stats{Writes: 2}, time.Minute)
+
+		published, err := ReadStats(c)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(published) != 2 || published["web-1"].Hits != 3 || published["web-2"].Writes != 2 {
+			t.Errorf("%s: expected the stats of both instances, got %+v", name, published)
+		}
+
+		// an instance that has stopped publishing is dropped
+		_ = PublishStats(c, "web-1", Stats{Hits: 4}, 0)
+		published, _ = ReadStats(c)
+		if len(published) != 1 || published["web-1"].Hits != 4 {
+			t.Errorf("%s: expected only the latest instance, got %+v", name, published)
+		}
+
+		_ = c.Forget(StatsKey)
+	}
+}
@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TaggedCache stores entries under one or more tags, so that everything stored with a tag
+// can be removed at once with Flush, without knowing the keys.
+//
+// As in Laravel, each tag has an id, kept in the underlying cache. Tagged keys are stored
+// under a namespace made from the ids of their tags, and flushing a tag gives it a new id,
+// so the old entries can no longer be reached. They stay in the underlying cache until they
+// expire or are evicted, so tagged entries should be given an expiry.
+type TaggedCache struct {
+	cache Cache
+	names []string
+}
+
+// Tags returns c restricted to the given tags. The order of the tags does not matter.
+func Tags(c Cache, names ...string) *TaggedCache {
+	sorted := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+
+	return &TaggedCache{cache: c, names: sorted}
+}
+
+func (t *TaggedCache) Tags(names ...string) *TaggedCache {
+	return Tags(t.cache, append(append([]string{}, t.names...), names...)...)
+}
+
+func (t *TaggedCache) Has(str string) (bool, error) {
+	key, err := t.key(str)
+	if err != nil {
+		return false, err
+	}
+	return t.cache.Has(key)
+}
+
+func (t *TaggedCache) Get(str string) (interface{}, error) {
+	key, err := t.key(str)
+	if err != nil {
+		return nil, err
+	}
+	return t.cache.Get(key)
+}
+
+// Scan decodes the value stored under str into dest, which must be a pointer
+func (t *TaggedCache) Scan(str string, dest interface{}) error {
+	key, err := t.key(str)
+	if err != nil {
+		return err
+	}
+	return t.cache.Scan(key, dest)
+}
+
+func (t *TaggedCache) Set(str string, value interface{}, expires ...int) error {
+	key, err := t.key(str)
+	if err != nil {
+		return err
+	}
+	return t.cache.Set(key, value, expires...)
+}
+
+func (t *TaggedCache) Forget(str string) error {
+	key, err := t.key(str)
+	if err != nil {
+		return err
+	}
+	return t.cache.Forget(key)
+}
+
+func (t *TaggedCache) Increment(str string, by int) (int, error) {
+	key, err := t.key(str)
+	if err != nil {
+		return 0, err
+	}
+	return t.cache.Increment(key, by)
+}
+
+func (t *TaggedCache) Decrement(str string, by int) (int, error) {
+	return t.Increment(str, -by)
+}
+
+func (t *TaggedCache) Add(str string, value interface{}, expires ...int) (bool, error) {
+	key, err := t.key(str)
+	if err != nil {
+		return false, err
+	}
+	return t.cache.Add(key, value, expires...)
+}
+
+func (t *TaggedCache) Pull(str string) (interface{}, error) {
+	key, err := t.key(str)
+	if err != nil {
+		return nil, err
+	}
+	return t.cache.Pull(key)
+}
+
+// Lock returns a lock in the underlying cache; locks are not tagged
+func (t *TaggedCache) Lock(name string, ttl int) *Lock {
+	return t.cache.Lock(name, ttl)
+}
+
+// EmptyByMatch removes the entries with these tags whose keys start with str
+func (t *TaggedCache) EmptyByMatch(str string) error {
+	key, err := t.key(str)
+	if err != nil {
+		return err
+	}
+	return t.cache.EmptyByMatch(key)
+}
+
+// Empty is the same as Flush
+func (t *TaggedCache) Empty() error {
+	return t.Flush()
+}
+
+// Flush removes every entry stored with any of these tags, including entries stored with
+// other tags as well
+func (t *TaggedCache) Flush() error {
+	for _, name := range t.names {
+		err := t.resetTag(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key returns the key under which str is stored for these tags
+func (t *TaggedCache) key(str string) (string, error) {
+	ids := make([]string, 0, len(t.names))
+	for _, name := range t.names {
+		id, err := t.tagID(name)
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+
+	namespace := sha1.Sum([]byte(strings.Join(ids, "|")))
+
+	return fmt.Sprintf("tagged:%s:%s", hex.EncodeToString(namespace[:]), str), nil
+}
+
+// tagID returns the current id of the tag name, creating one if it has none
+func (t *TaggedCache) tagID(name string) (string, error) {
+	id, err := GetAs[string](t.cache, tagKey(name))
+	if !errors.Is(err, ErrCacheMiss) {
+		return id, err
+	}
+
+	// when two instances create an id at once, the first one stored is used by both
+	id, err = newTagID()
+	if err != nil {
+		return "", err
+	}
+
+	added, err := t.cache.Add(tagKey(name), id)
+	if err != nil || added {
+		return id, err
+	}
+
+	return GetAs[string](t.cache, tagKey(name))
+}
+
+// resetTag gives the tag name a new id
+func (t *TaggedCache) resetTag(name string) error {
+	id, err := newTagID()
+	if err != nil {
+		return err
+	}
+
+	return t.cache.Set(tagKey(name), id)
+}
+
+func newTagID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func tagKey(name string) string {
+	return "tag:" + name + ":id"
+}
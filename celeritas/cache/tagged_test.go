@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCache_Tags(t *testing.T) {
+	for name, c := range caches() {
+		err := c.Tags("users", "team:5").Set("fragment", "both", 60)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		err = c.Tags("users").Set("fragment", "users", 60)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		err = c.Tags("posts").Set("fragment", "posts", 60)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		// the same key under other tags is a different entry, and the order of tags does not matter
+		x, err := c.Tags("team:5", "users").Get("fragment")
+		if err != nil || x != "both" {
+			t.Errorf("%s: expected both, got %v %v", name, x, err)
+		}
+
+		if ok, _ := c.Has("fragment"); ok {
+			t.Errorf("%s: tagged entry found without its tags", name)
+		}
+
+		err = c.Tags("users").Flush()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = c.Tags("users", "team:5").Get("fragment")
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: entry tagged users and team:5 survived flushing users: %v", name, err)
+		}
+
+		_, err = c.Tags("users").Get("fragment")
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: entry tagged users survived flushing users: %v", name, err)
+		}
+
+		x, err = c.Tags("posts").Get("fragment")
+		if err != nil || x != "posts" {
+			t.Errorf("%s: entry tagged posts was flushed with users: %v %v", name, x, err)
+		}
+	}
+}
+
+func TestCache_Tags_Remember(t *testing.T) {
+	for name, c := range caches() {
+		tagged := c.Tags("remembered")
+		_ = tagged.Flush()
+
+		calls := 0
+		fn := func() (int, error) {
+			calls++
+			return 42, nil
+		}
+
+		for i := 0; i < 2; i++ {
+			n, err := Remember(tagged, "answer", 60, fn)
+			if err != nil || n != 42 {
+				t.Errorf("%s: expected 42, got %d %v", name, n, err)
+			}
+		}
+
+		_ = tagged.Flush()
+		_, _ = Remember(tagged, "answer", 60, fn)
+
+		if calls != 2 {
+			t.Errorf("%s: expected 2 calls, got %d", name, calls)
+		}
+	}
+}
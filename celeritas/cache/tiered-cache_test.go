@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+// newTestTiered creates a tiered cache in front of remote, as one instance of an application would
+func newTestTiered(t *testing.T, remote *RedisCache) *TieredCache {
+	local, err := NewMemoryCache(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tiered, err := NewTieredCache(local, remote, 60, remote.Conn, remote.Prefix+":invalidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = tiered.Close()
+		local.Close()
+	})
+
+	return tiered
+}
+
+// eventually waits for cond, since invalidations are delivered in the background
+func eventually(cond func() bool) bool {
+	for i := 0; i < 300; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestTieredCache_ReadsThrough(t *testing.T) {
+	tiered := newTestTiered(t, &testRedisCache)
+
+	_ = testRedisCache.Set("tiered", "remote")
+
+	x, err := tiered.Get("tiered")
+	if err != nil || x != "remote" {
+		t.Fatalf("expected the remote value, got %v %v", x, err)
+	}
+
+	if ok, _ := tiered.Local.Has("tiered"); !ok {
+		t.Error("value read from redis was not kept locally")
+	}
+
+	// a local copy is served without going to redis
+	testRedisServer.Del("test-celeritas:tiered")
+	x, _ = tiered.Get("tiered")
+	if x != "remote" {
+		t.Error("local copy was not used, got", x)
+	}
+}
+
+func TestTieredCache_Invalidates(t *testing.T) {
+	first := newTestTiered(t, &testRedisCache)
+	second := newTestTiered(t, &testRedisCache)
+
+	err := first.Set("shared", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, _ := second.Get("shared")
+	if x != "one" {
+		t.Fatal("expected one, got", x)
+	}
+
+	err = first.Set("shared", "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !eventually(func() bool { ok, _ := second.Local.Has("shared"); return !ok }) {
+		t.Fatal("second instance kept its stale copy")
+	}
+
+	x, _ = second.Get("shared")
+	if x != "two" {
+		t.Error("expected two, got", x)
+	}
+
+	_ = second.Set("other", "x")
+	_, _ = first.Get("other")
+	_ = second.Empty()
+
+	if !eventually(func() bool { ok, _ := first.Local.Has("other"); return !ok }) {
+		t.Error("emptying the cache on one instance did not empty the other")
+	}
+}
+
+func TestTieredCache_Reconnects(t *testing.T) {
+	// the server is restarted, so the test has one of its own
+	s := miniredis.RunT(t)
+	remote := &RedisCache{
+		Conn: &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", s.Addr())
+			},
+			TestOnBorrow: func(conn redis.Conn, _ time.Time) error {
+				_, err := conn.Do("PING")
+				return err
+			},
+		},
+		Prefix: "reconnect",
+	}
+
+	first := newTestTiered(t, remote)
+	second := newTestTiered(t, remote)
+
+	_ = first.Set("reconnect", "value")
+	_, _ = second.Get("reconnect")
+
+	// losing the subscription empties the local cache, since invalidations may have been
+	// missed while it was gone
+	s.Close()
+	if err := s.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !eventually(func() bool { ok, _ := second.Local.Has("reconnect"); return !ok }) {
+		t.Fatal("local cache kept after the subscription was lost")
+	}
+
+	// once subscribed again, invalidations are applied as before
+	if !eventually(func() bool { return s.PubSubNumSub(first.Channel)[first.Channel] == 2 }) {
+		t.Fatal("subscribers did not reconnect")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	_ = first.Set("reconnect", "value")
+	_, _ = second.Get("reconnect")
+	_ = first.Set("reconnect", "changed")
+
+	if !eventually(func() bool { ok, _ := second.Local.Has("reconnect"); return !ok }) {
+		t.Error("invalidations not received after reconnecting")
+	}
+}
@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TieredCache fronts a shared cache, such as redis or badger, with a short lived MemoryCache,
+// which should use the same Codec.
+// Reads are served from memory when they can be; everything else goes to Remote, and then
+// drops what it changed from memory. When Pubsub is set, changes are also announced on a
+// redis channel, so that every instance of the application drops its own copy.
+//
+// A local copy can be up to TTL seconds out of date if an announcement is missed, so TTL
+// should be kept short.
+type TieredCache struct {
+	Local   *MemoryCache
+	Remote  Cache
+	TTL     int
+	Pubsub  *redis.Pool
+	Channel string
+
+	// done stops the subscriber
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// invalidation messages are an operation, a colon and a key or pattern
+const (
+	invalidateKey   = "forget"
+	invalidateMatch = "match"
+	invalidateAll   = "empty"
+)
+
+// NewTieredCache creates a TieredCache that keeps entries in local for ttl seconds. If pool is
+// not nil, it subscribes to channel, and returns once the subscription is in place.
+func NewTieredCache(local *MemoryCache, remote Cache, ttl int, pool *redis.Pool, channel string) (*TieredCache, error) {
+	t := &TieredCache{
+		Local:   local,
+		Remote:  remote,
+		TTL:     ttl,
+		Pubsub:  pool,
+		Channel: channel,
+		done:    make(chan struct{}),
+	}
+
+	if pool == nil {
+		return t, nil
+	}
+
+	subscribed := make(chan error, 1)
+	t.wg.Add(1)
+	go t.subscribe(subscribed)
+
+	if err := <-subscribed; err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *TieredCache) Has(str string) (bool, error) {
+	if ok, _ := t.Local.Has(str); ok {
+		return true, nil
+	}
+	return t.Remote.Has(str)
+}
+
+func (t *TieredCache) Get(str string) (interface{}, error) {
+	item, err := t.Local.Get(str)
+	if err == nil {
+		return item, nil
+	}
+
+	item, err = t.Remote.Get(str)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = t.Local.Set(str, item, t.TTL)
+
+	return item, nil
+}
+
+// Scan decodes the value stored under str into dest, which must be a pointer
+func (t *TieredCache) Scan(str string, dest interface{}) error {
+	err := t.Local.Scan(str, dest)
+	if err == nil {
+		return nil
+	}
+
+	err = t.Remote.Scan(str, dest)
+	if err != nil {
+		return err
+	}
+
+	_ = t.Local.Set(str, reflect.ValueOf(dest).Elem().Interface(), t.TTL)
+
+	return nil
+}
+
+func (t *TieredCache) Set(str string, value interface{}, expires ...int) error {
+	err := t.Remote.Set(str, value, expires...)
+	if err != nil {
+		return err
+	}
+
+	return t.invalidate(invalidateKey, str)
+}
+
+func (t *TieredCache) Forget(str string) error {
+	err := t.Remote.Forget(str)
+	if err != nil {
+		return err
+	}
+
+	return t.invalidate(invalidateKey, str)
+}
+
+func (t *TieredCache) EmptyByMatch(str string) error {
+	err := t.Remote.EmptyByMatch(str)
+	if err != nil {
+		return err
+	}
+
+	return t.invalidate(invalidateMatch, str)
+}
+
+func (t *TieredCache) Empty() error {
+	err := t.Remote.Empty()
+	if err != nil {
+		return err
+	}
+
+	return t.invalidate(invalidateAll, "")
+}
+
+func (t *TieredCache) Increment(str string, by int) (int, error) {
+	n, err := t.Remote.Increment(str, by)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, t.invalidate(invalidateKey, str)
+}
+
+func (t *TieredCache) Decrement(str string, by int) (int, error) {
+	return t.Increment(str, -by)
+}
+
+func (t *TieredCache) Add(str string, value interface{}, expires ...int) (bool, error) {
+	added, err := t.Remote.Add(str, value, expires...)
+	if err != nil || !added {
+		return added, err
+	}
+
+	return true, t.invalidate(invalidateKey, str)
+}
+
+func (t *TieredCache) Pull(str string) (interface{}, error) {
+	item, err := t.Remote.Pull(str)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, t.invalidate(invalidateKey, str)
+}
+
+// Lock returns a lock held in Remote, so that it is shared with the other instances
+func (t *TieredCache) Lock(name string, ttl int) *Lock {
+	return t.Remote.Lock(name, ttl)
+}
+
+// Tags returns the cache restricted to the given tags. Tag ids are cached locally like
+// any other entry, and flushing a tag drops them on every instance.
+func (t *TieredCache) Tags(names ...string) *TaggedCache {
+	return Tags(t, names...)
+}
+
+// invalidate drops key, or the keys matching it, from the local cache, and announces the
+// change to the other instances
+func (t *TieredCache) invalidate(op, key string) error {
+	t.drop(op, key)
+
+	if t.Pubsub == nil {
+		return nil
+	}
+
+	conn := t.Pubsub.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PUBLISH", t.Channel, op+":"+key)
+	return err
+}
+
+func (t *TieredCache) drop(op, key string) {
+	switch op {
+	case invalidateKey:
+		_ = t.Local.Forget(key)
+	case invalidateMatch:
+		_ = t.Local.EmptyByMatch(key)
+	case invalidateAll:
+		_ = t.Local.Empty()
+	}
+}
+
+// subscribe listens for invalidations until Close is called, reconnecting when the
+// connection is lost. The first subscription's result is sent on subscribed.
+func (t *TieredCache) subscribe(subscribed chan<- error) {
+	defer t.wg.Done()
+
+	first := true
+	for {
+		err := t.listen(func() {
+			if first {
+				first = false
+				subscribed <- nil
+			} else {
+				// announcements may have been missed while disconnected
+				_ = t.Local.Empty()
+			}
+		})
+		if first {
+			subscribed <- err
+			return
+		}
+
+		// announcements may be missed until the subscription is back
+		_ = t.Local.Empty()
+
+		select {
+		case <-t.done:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// listen subscribes to Channel and applies invalidations until the connection fails or
+// Close is called. ready is called once the subscription is confirmed.
+func (t *TieredCache) listen(ready func()) error {
+	psc := redis.PubSubConn{Conn: t.Pubsub.Get()}
+	defer psc.Close()
+
+	if err := psc.Subscribe(t.Channel); err != nil {
+		return err
+	}
+
+	// the connection is closed only once the goroutine that unsubscribes has finished with it
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		select {
+		case <-t.done:
+			_ = psc.Unsubscribe()
+		case <-stop:
+		}
+	}()
+
+	for {
+		// wait as long as it takes for a message, whatever read timeout the pool sets
+		switch v := psc.ReceiveWithTimeout(0).(type) {
+		case redis.Message:
+			op, key, _ := strings.Cut(string(v.Data), ":")
+			t.drop(op, key)
+		case redis.Subscription:
+			if v.Kind == "subscribe" {
+				ready()
+			}
+			if v.Count == 0 {
+				return nil
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// Close stops listening for invalidations. It does not close Local or Remote.
+func (t *TieredCache) Close() error {
+	if t.done == nil {
+		return nil
+	}
+
+	select {
+	case <-t.done:
+	default:
+		close(t.done)
+	}
+	t.wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrWrongType is returned by GetAs when the cached value is not of the type asked for
+var ErrWrongType = errors.New("cache: value has the wrong type")
+
+// remembering makes concurrent misses on the same key, in the same cache, wait for one
+// computation of the value
+var remembering singleflight.Group
+
+// GetAs gets the value stored under key as a T. It returns ErrCacheMiss when there is no
+// value, and ErrWrongType when the value is not a T. With the gob codec, types other than the
+// built in ones must be registered with gob.Register; the other codecs decode into T.
+func GetAs[T any](c Cache, key string) (T, error) {
+	var t T
+
+	err := c.Scan(key, &t)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return t, nil
+}
+
+// Remember returns the value stored under key. On a miss, it calls fn, and stores its result
+// for ttl seconds, or for good when ttl is 0. Concurrent misses on the same key call fn once,
+// and all receive its result. Errors other than a miss are returned without calling fn, as
+// are fn's errors, which are not cached. If the value cannot be stored, it is returned along
+// with the error.
+func Remember[T any](c Cache, key string, ttl int, fn func() (T, error)) (T, error) {
+	v, err := GetAs[T](c, key)
+	if !errors.Is(err, ErrCacheMiss) {
+		return v, err
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+
+	// the cache is part of the key, so that two caches do not share a computation
+	flight := fmt.Sprintf("%p:%s", c, key)
+	r, _, _ := remembering.Do(flight, func() (interface{}, error) {
+		value, err := fn()
+		if err != nil {
+			return result{err: err}, nil
+		}
+
+		if ttl > 0 {
+			err = c.Set(key, value, ttl)
+		} else {
+			err = c.Set(key, value)
+		}
+
+		return result{value: value, err: err}, nil
+	})
+
+	res := r.(result)
+	return res.value, res.err
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func caches() map[string]Cache {
+	return map[string]Cache{
+		"redis":    &testRedisCache,
+		"badger":   &testBadgerCache,
+		"memory":   testMemoryCache,
+		"database": &testDatabaseCache,
+	}
+}
+
+func TestCache_ErrCacheMiss(t *testing.T) {
+	for name, c := range caches() {
+		_, err := c.Get("never-set")
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: expected ErrCacheMiss, got %v", name, err)
+		}
+
+		inCache, err := c.Has("never-set")
+		if err != nil || inCache {
+			t.Errorf("%s: expected a miss without an error, got %t %v", name, inCache, err)
+		}
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	for name, c := range caches() {
+		err := c.Set("typed", "bar")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		s, err := GetAs[string](c, "typed")
+		if err != nil || s != "bar" {
+			t.Errorf("%s: expected bar, got %q %v", name, s, err)
+		}
+
+		n, err := GetAs[int](c, "typed")
+		if !errors.Is(err, ErrWrongType) || n != 0 {
+			t.Errorf("%s: expected ErrWrongType, got %d %v", name, n, err)
+		}
+
+		_, err = GetAs[string](c, "typed-missing")
+		if !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("%s: expected ErrCacheMiss, got %v", name, err)
+		}
+	}
+}
+
+func TestRemember(t *testing.T) {
+	for name, c := range caches() {
+		_ = c.Forget("remembered")
+
+		var calls int32
+		compute := func() (int, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return 42, nil
+		}
+
+		// concurrent misses compute the value once
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := Remember(c, "remembered", 60, compute)
+				if err != nil || v != 42 {
+					t.Errorf("%s: expected 42, got %d %v", name, v, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if calls != 1 {
+			t.Errorf("%s: expected one computation, got %d", name, calls)
+		}
+
+		// later calls are served from the cache
+		v, err := Remember(c, "remembered", 60, compute)
+		if err != nil || v != 42 || calls != 1 {
+			t.Errorf("%s: expected the cached value, got %d %v after %d calls", name, v, err, calls)
+		}
+	}
+}
+
+func TestRemember_Error(t *testing.T) {
+	for name, c := range caches() {
+		_ = c.Forget("remember-error")
+
+		failure := errors.New("database on fire")
+		_, err := Remember(c, "remember-error", 0, func() (string, error) {
+			return "", failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("%s: expected the function's error, got %v", name, err)
+		}
+
+		// errors are not cached
+		v, err := Remember(c, "remember-error", 0, func() (string, error) {
+			return "recovered", nil
+		})
+		if err != nil || v != "recovered" {
+			t.Errorf("%s: expected recovered, got %q %v", name, v, err)
+		}
+	}
+}
@@ -1,23 +1,34 @@
 package celeritas
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/alexedwards/scs/v2"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-sql-driver/mysql"
 	"github.com/gomodule/redigo/redis"
-	"github.com/joho/godotenv"
 	"github.com/robfig/cron/v3"
 	"github.com/tschenhau/celeritas/cache"
 	"github.com/tschenhau/celeritas/mailer"
+	"github.com/tschenhau/celeritas/queue"
 	"github.com/tschenhau/celeritas/render"
 	"github.com/tschenhau/celeritas/session"
 )
@@ -26,8 +37,12 @@ const version = "1.0.0"
 
 var myRedisCache *cache.RedisCache
 var myBadgerCache *cache.BadgerCache
+var myMemoryCache *cache.MemoryCache
+var myDatabaseCache *cache.DatabaseCache
+var myTieredCache *cache.TieredCache
 var redisPool *redis.Pool
 var badgerConn *badger.DB
+var queueBadgerConn *badger.DB
 
 // Celeritas is the overall type for the Celeritas package. Members that are exported in this type
 // are available to any application that uses it.
@@ -37,18 +52,28 @@ type Celeritas struct {
 	Version       string
 	ErrorLog      *log.Logger
 	InfoLog       *log.Logger
+	Logger        *slog.Logger
 	RootPath      string
 	Routes        *chi.Mux
 	Render        *render.Render
 	Session       *scs.SessionManager
+	UserSessions  *session.UserSessions // nil for session stores that cannot list sessions
 	DB            Database
 	JetViews      *jet.Set
-	config        config
 	EncryptionKey string
 	Cache         cache.Cache
 	Scheduler     *cron.Cron
 	Mail          mailer.Mail
+	Queue         *queue.Manager
 	Server        Server
+	// Config holds the settings the application was started with
+	Config Config
+	// ShutdownTimeout is how long ListenAndServe waits for in-flight requests
+	// and shutdown hooks to finish before giving up
+	ShutdownTimeout time.Duration
+	startupHooks    []func() error
+	shutdownHooks   []func(context.Context) error
+	logFile         io.Closer
 }
 
 type Server struct {
@@ -58,51 +83,60 @@ type Server struct {
 	URL        string
 }
 
-type config struct {
-	port        string
-	renderer    string
-	cookie      cookieConfig
-	sessionType string
-	database    databaseConfig
-	redis       redisConfig
-}
-
 // New reads the .env file, creates our application config, populates the Celeritas type with settings
 // based on .env values, and creates necessary folders and files if they don't exist
 func (c *Celeritas) New(rootPath string) error {
-	pathConfig := initPaths{
-		rootPath:    rootPath,
-		folderNames: []string{"handlers", "migrations", "views", "mail", "data", "public", "tmp", "logs", "middleware"},
+	err := c.checkDotEnv(rootPath)
+	if err != nil {
+		return err
 	}
 
-	err := c.Init(pathConfig)
+	// read .env
+	cfg, err := LoadConfig(rootPath)
 	if err != nil {
 		return err
 	}
 
-	err = c.checkDotEnv(rootPath)
+	return c.NewWithConfig(rootPath, cfg)
+}
+
+// NewWithConfig populates the Celeritas type from cfg instead of from .env and the environment,
+// and creates necessary folders if they don't exist. It returns a *ConfigError listing every
+// problem if cfg does not validate. Driver names and modes are lowercased first, by Normalize.
+func (c *Celeritas) NewWithConfig(rootPath string, cfg Config) error {
+	cfg.Normalize()
+	err := cfg.Validate()
 	if err != nil {
 		return err
 	}
 
-	// read .env
-	err = godotenv.Load(rootPath + "/.env")
+	pathConfig := initPaths{
+		rootPath:    rootPath,
+		folderNames: []string{"handlers", "migrations", "views", "mail", "data", "public", "tmp", "logs", "middleware"},
+	}
+
+	err = c.Init(pathConfig)
 	if err != nil {
 		return err
 	}
 
+	c.RootPath = rootPath
+	c.Config = cfg
+
 	// create loggers
-	infoLog, errorLog := c.startLoggers()
+	infoLog, errorLog, err := c.startLoggers()
+	if err != nil {
+		return err
+	}
 
 	// connect to database
-	if os.Getenv("DATABASE_TYPE") != "" {
-		db, err := c.OpenDB(os.Getenv("DATABASE_TYPE"), c.BuildDSN())
+	if cfg.Database.Type != "" {
+		db, err := c.OpenDB(cfg.Database.Type, c.BuildDSN())
 		if err != nil {
-			errorLog.Println(err)
-			os.Exit(1)
+			return err
 		}
 		c.DB = Database{
-			DataType: os.Getenv("DATABASE_TYPE"),
+			DataType: cfg.Database.Type,
 			Pool:     db,
 		}
 	}
@@ -110,86 +144,136 @@ func (c *Celeritas) New(rootPath string) error {
 	scheduler := cron.New()
 	c.Scheduler = scheduler
 
-	if os.Getenv("CACHE") == "redis" || os.Getenv("SESSION_TYPE") == "redis" {
-		myRedisCache = c.createClientRedisCache()
-		c.Cache = myRedisCache
-		redisPool = myRedisCache.Conn
+	if cfg.Cache == "redis" || cfg.SessionType == "redis" || cfg.Queue.Driver == "redis" {
+		redisPool, err = c.createRedisPool()
+		if err != nil {
+			return err
+		}
 	}
 
-	if os.Getenv("CACHE") == "badger" {
-		myBadgerCache = c.createClientBadgerCache()
-		c.Cache = myBadgerCache
-		badgerConn = myBadgerCache.Conn
+	if cfg.Cache == "redis" {
+		myRedisCache, err = c.createClientRedisCache(redisPool)
+		if err != nil {
+			return err
+		}
+	}
 
-		_, err = c.Scheduler.AddFunc("@daily", func() {
-			_ = myBadgerCache.Conn.RunValueLogGC(0.7)
+	if cfg.Cache == "badger" || cfg.SessionType == "badger" {
+		conn, err := c.createBadgerConn()
+		if err != nil {
+			return err
+		}
+		badgerConn = conn
+
+		_, err = c.Scheduler.AddFunc(cfg.Badger.GCSchedule, func() {
+			_ = badgerConn.RunValueLogGC(cfg.Badger.GCRatio)
 		})
 		if err != nil {
 			return err
 		}
 	}
 
+	if cfg.Cache == "badger" {
+		myBadgerCache, err = c.createClientBadgerCache(badgerConn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Cache == "database" {
+		myDatabaseCache, err = c.createClientDatabaseCache(c.DB.Pool)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.Scheduler.AddFunc("@hourly", func() {
+			_, err := myDatabaseCache.Prune()
+			if err != nil {
+				c.Logger.Error("pruning the cache table", "error", err)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Cache, err = c.createCache()
+	if err != nil {
+		return err
+	}
+
+	err = c.publishCacheStats()
+	if err != nil {
+		return err
+	}
+
+	c.AppName = cfg.AppName
 	c.InfoLog = infoLog
 	c.ErrorLog = errorLog
-	c.Debug, _ = strconv.ParseBool(os.Getenv("DEBUG"))
+	c.Debug = cfg.Debug
 	c.Version = version
-	c.RootPath = rootPath
-	c.Mail = c.createMailer()
-	c.Routes = c.routes().(*chi.Mux)
+	c.ShutdownTimeout = cfg.ShutdownTimeout
+	c.Mail, err = c.createMailer()
+	if err != nil {
+		return err
+	}
 
-	c.config = config{
-		port:     os.Getenv("PORT"),
-		renderer: os.Getenv("RENDERER"),
-		cookie: cookieConfig{
-			name:     os.Getenv("COOKIE_NAME"),
-			lifetime: os.Getenv("COOKIE_LIFETIME"),
-			persist:  os.Getenv("COOKIE_PERSISTS"),
-			secure:   os.Getenv("COOKIE_SECURE"),
-			domain:   os.Getenv("COOKIE_DOMAIN"),
-		},
-		sessionType: os.Getenv("SESSION_TYPE"),
-		database: databaseConfig{
-			database: os.Getenv("DATABASE_TYPE"),
-			dsn:      c.BuildDSN(),
-		},
-		redis: redisConfig{
-			host:     os.Getenv("REDIS_HOST"),
-			password: os.Getenv("REDIS_PASSWORD"),
-			prefix:   os.Getenv("REDIS_PREFIX"),
-		},
-	}
-
-	secure := true
-	if strings.ToLower(os.Getenv("SECURE")) == "false" {
-		secure = false
+	if cfg.Queue.Driver != "" {
+		c.Queue, err = c.createQueue()
+		if err != nil {
+			return err
+		}
+		c.Mail.UseQueue(c.Queue)
 	}
 
+	c.Routes = c.routes().(*chi.Mux)
+
 	c.Server = Server{
-		ServerName: os.Getenv("SERVER_NAME"),
-		Port:       os.Getenv("PORT"),
-		Secure:     secure,
-		URL:        os.Getenv("APP_URL"),
+		ServerName: cfg.ServerName,
+		Port:       strconv.Itoa(cfg.Port),
+		Secure:     cfg.Secure,
+		URL:        cfg.AppURL,
 	}
 
+	c.EncryptionKey = cfg.Key
+
 	// create session
 
 	sess := session.Session{
-		CookieLifetime: c.config.cookie.lifetime,
-		CookiePersist:  c.config.cookie.persist,
-		CookieName:     c.config.cookie.name,
-		SessionType:    c.config.sessionType,
-		CookieDomain:   c.config.cookie.domain,
+		CookieLifetime: strconv.Itoa(cfg.Cookie.Lifetime),
+		CookiePersist:  strconv.FormatBool(cfg.Cookie.Persist),
+		CookieName:     cfg.Cookie.Name,
+		SessionType:    cfg.SessionType,
+		CookieDomain:   cfg.Cookie.Domain,
+		CookieSecure:   strconv.FormatBool(cfg.Cookie.Secure),
 	}
 
-	switch c.config.sessionType {
+	switch cfg.SessionType {
 	case "redis":
-		sess.RedisPool = myRedisCache.Conn
-	case "mysql", "postgres", "mariadb", "postgresql":
+		sess.RedisPool = redisPool
+	case "mysql", "postgres", "mariadb", "postgresql", "sqlite", "sqlite3":
 		sess.DBPool = c.DB.Pool
+	case "badger":
+		sess.BadgerConn = badgerConn
+	case "cache":
+		sess.Cache = c.Cache
+	case "cookie":
+		sess.EncryptionKeys = append([]string{c.EncryptionKey}, cfg.PreviousKeys...)
 	}
 
-	c.Session = sess.InitSession()
-	c.EncryptionKey = os.Getenv("KEY")
+	c.Session, err = sess.InitSession()
+	if err != nil {
+		return err
+	}
+
+	c.UserSessions = sess.InitUserSessions(c.Session)
+	if c.UserSessions != nil {
+		c.UserSessions.Logger = c.Logger
+	}
+
+	if c.Debug && cfg.Mail.Preview {
+		c.mountMailPreview(c.Routes)
+	}
 
 	if c.Debug {
 		var views = jet.NewSet(
@@ -203,6 +287,7 @@ func (c *Celeritas) New(rootPath string) error {
 		)
 		c.JetViews = views
 	}
+	c.JetViews.AddGlobalFunc("cache", c.jetCache)
 
 	c.createRenderer()
 	go c.Mail.ListenForMail()
@@ -223,10 +308,13 @@ func (c *Celeritas) Init(p initPaths) error {
 	return nil
 }
 
-// ListenAndServe starts the web server
-func (c *Celeritas) ListenAndServe() {
+// ListenAndServe runs the startup hooks, starts the scheduler and the web server, and then
+// blocks until the server fails or the process receives SIGINT or SIGTERM. On a signal,
+// the server stops accepting new connections and waits up to ShutdownTimeout for in-flight
+// requests to complete before calling Shutdown to release the application's resources.
+func (c *Celeritas) ListenAndServe() error {
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", os.Getenv("PORT")),
+		Addr:         fmt.Sprintf(":%d", c.Config.Port),
 		ErrorLog:     c.ErrorLog,
 		Handler:      c.Routes,
 		IdleTimeout:  30 * time.Second,
@@ -234,136 +322,548 @@ func (c *Celeritas) ListenAndServe() {
 		WriteTimeout: 600 * time.Second,
 	}
 
-	if c.DB.Pool != nil {
-		defer c.DB.Pool.Close()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	err := c.runStartupHooks()
+	if err != nil {
+		c.ErrorLog.Println(err)
+		_ = c.Shutdown(context.Background())
+		return err
 	}
 
-	if redisPool != nil {
-		defer redisPool.Close()
+	if c.Scheduler != nil {
+		c.Scheduler.Start()
 	}
 
-	if badgerConn != nil {
-		defer badgerConn.Close()
+	serverErrors := make(chan error, 1)
+	go func() {
+		c.InfoLog.Printf("Listening on port %d", c.Config.Port)
+		serverErrors <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErrors:
+		// the server never started, or stopped on its own
+		c.ErrorLog.Println(err)
+		_ = c.Shutdown(context.Background())
+		return err
+	case sig := <-quit:
+		c.InfoLog.Printf("Received %s, shutting down", sig)
 	}
 
-	c.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
-	err := srv.ListenAndServe()
-	c.ErrorLog.Fatal(err)
-}
+	ctx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
 
-func (c *Celeritas) checkDotEnv(path string) error {
-	err := c.CreateFileIfNotExists(fmt.Sprintf("%s/.env", path))
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("draining requests: %w", err))
+	}
+
+	if err := c.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	err = errors.Join(errs...)
 	if err != nil {
+		c.ErrorLog.Println(err)
 		return err
 	}
+
+	c.InfoLog.Println("Server stopped")
 	return nil
 }
 
-func (c *Celeritas) startLoggers() (*log.Logger, *log.Logger) {
-	var infoLog *log.Logger
-	var errorLog *log.Logger
+// Work runs the startup hooks and then queue workers for the given queues, or for
+// QUEUE_NAME if none are given, until the process receives SIGINT or SIGTERM. Workers
+// then stop taking new jobs, and Work waits up to ShutdownTimeout for the jobs that are
+// running to finish before calling Shutdown. `celeritas queue:work` runs the application
+// with "queue:work" as its first argument, which should call Work instead of ListenAndServe.
+func (c *Celeritas) Work(queues ...string) error {
+	if c.Queue == nil {
+		return errors.New("no queue configured; set QUEUE in .env")
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
 
-	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	err := c.runStartupHooks()
+	if err != nil {
+		c.ErrorLog.Println(err)
+		_ = c.Shutdown(context.Background())
+		return err
+	}
+
+	workCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	workerErrors := make(chan error, 1)
+	go func() {
+		c.InfoLog.Printf("Processing jobs with %d workers", c.Config.Queue.Concurrency)
+		workerErrors <- c.Queue.Work(workCtx, queues...)
+	}()
+
+	select {
+	case err = <-workerErrors:
+		c.ErrorLog.Println(err)
+		_ = c.Shutdown(context.Background())
+		return err
+	case sig := <-quit:
+		c.InfoLog.Printf("Received %s, shutting down", sig)
+	}
 
-	return infoLog, errorLog
+	ctx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	stopWorkers()
+	select {
+	case <-workerErrors:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for running jobs: %w", ctx.Err()))
+	}
+
+	if err := c.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		c.ErrorLog.Println(err)
+		return err
+	}
+
+	c.InfoLog.Println("Workers stopped")
+	return nil
+}
+
+func (c *Celeritas) checkDotEnv(path string) error {
+	err := c.CreateFileIfNotExists(fmt.Sprintf("%s/.env", path))
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
 func (c *Celeritas) createRenderer() {
 	myRenderer := render.Render{
-		Renderer: c.config.renderer,
-		RootPath: c.RootPath,
-		Port:     c.config.port,
-		JetViews: c.JetViews,
-		Session:  c.Session,
+		Renderer:   c.Config.Renderer,
+		RootPath:   c.RootPath,
+		Secure:     c.Server.Secure,
+		Port:       c.Server.Port,
+		ServerName: c.Server.ServerName,
+		JetViews:   c.JetViews,
+		Session:    c.Session,
 	}
 	c.Render = &myRenderer
 }
 
-func (c *Celeritas) createMailer() mailer.Mail {
-	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+func (c *Celeritas) createMailer() (mailer.Mail, error) {
+	cfg := c.Config.Mail
 	m := mailer.Mail{
-		Domain:      os.Getenv("MAIL_DOMAIN"),
+		Domain:      cfg.Domain,
 		Templates:   c.RootPath + "/mail",
-		Host:        os.Getenv("SMTP_HOST"),
-		Port:        port,
-		Username:    os.Getenv("SMTP_USERNAME"),
-		Password:    os.Getenv("SMTP_PASSWORD"),
-		Encryption:  os.Getenv("SMTP_ENCRYPTION"),
-		FromName:    os.Getenv("FROM_NAME"),
-		FromAddress: os.Getenv("FROM_ADDRESS"),
+		Host:        cfg.SMTPHost,
+		Port:        cfg.SMTPPort,
+		Username:    cfg.SMTPUsername,
+		Password:    cfg.SMTPPassword,
+		Encryption:  cfg.SMTPEncryption,
+		FromName:    cfg.FromName,
+		FromAddress: cfg.FromAddress,
 		Jobs:        make(chan mailer.Message, 20),
 		Results:     make(chan mailer.Result, 20),
-		API:         os.Getenv("MAILER_API"),
-		APIKey:      os.Getenv("MAILER_KEY"),
-		APIUrl:      os.Getenv("MAILER_URL"),
+		API:         cfg.API,
+		APIKey:      cfg.APIKey,
+		APIUrl:      cfg.APIURL,
+		Workers:     cfg.Workers,
+		Logger:      c.Logger,
+		Renderer:    cfg.Renderer,
+		Layout:      cfg.Layout,
+		// like the views, templates are parsed once unless debugging
+		CacheTemplates: !c.Debug,
 	}
-	return m
+
+	switch cfg.API {
+	case "log":
+		m.Transport = &mailer.LogTransport{Logger: c.Logger}
+	case "file":
+		m.Transport = &mailer.FileTransport{Dir: c.RootPath + "/tmp/mail"}
+	}
+
+	if cfg.DKIMKey != "" {
+		path := cfg.DKIMKey
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.RootPath, path)
+		}
+
+		domain := cfg.DKIMDomain
+		if domain == "" {
+			domain = cfg.Domain
+		}
+
+		dkim, err := mailer.LoadDKIM(path, cfg.DKIMSelector, domain)
+		if err != nil {
+			return m, err
+		}
+		m.DKIM = dkim
+	}
+
+	return m, nil
+}
+
+// createQueue creates the job queue manager for the driver set in QUEUE. The badger driver
+// uses a database of its own, so that emptying a badger cache does not remove queued jobs.
+func (c *Celeritas) createQueue() (*queue.Manager, error) {
+	cfg := c.Config.Queue
+
+	var driver queue.Queue
+	switch cfg.Driver {
+	case "redis":
+		driver = &queue.RedisQueue{
+			Conn:   redisPool,
+			Prefix: c.Config.Redis.Prefix,
+		}
+	case "database":
+		driver = &queue.SQLQueue{
+			DB:     c.DB.Pool,
+			DBType: c.DB.DataType,
+		}
+	case "badger":
+		db, err := c.createQueueBadgerConn()
+		if err != nil {
+			return nil, err
+		}
+		queueBadgerConn = db
+		driver = &queue.BadgerQueue{Conn: db}
+	default:
+		return nil, fmt.Errorf("unsupported QUEUE %q", cfg.Driver)
+	}
+
+	return &queue.Manager{
+		Queue:        driver,
+		DefaultQueue: cfg.Name,
+		Concurrency:  cfg.Concurrency,
+		MaxAttempts:  cfg.MaxAttempts,
+		RetryAfter:   cfg.RetryAfter,
+		Logger:       c.Logger,
+	}, nil
+}
+
+// createCache returns the cache for the driver set in CACHE. An empty CACHE gets the memory
+// cache. With CACHE_LOCAL_TTL set, a redis, badger or database cache is put behind a tiered cache; for
+// redis, local copies are dropped on every instance through redis pub/sub whenever an entry
+// changes.
+func (c *Celeritas) createCache() (cache.Cache, error) {
+	var remote cache.Cache
+	switch c.Config.Cache {
+	case "redis":
+		remote = myRedisCache
+	case "badger":
+		remote = myBadgerCache
+	case "database":
+		remote = myDatabaseCache
+	}
+
+	if remote != nil && c.Config.MemoryCache.LocalTTL == 0 {
+		return remote, nil
+	}
+
+	memory, err := cache.NewMemoryCache(int64(c.Config.MemoryCache.MaxSize) << 20)
+	if err != nil {
+		return nil, err
+	}
+	memory.Codec, err = c.cacheCodec()
+	if err != nil {
+		return nil, err
+	}
+	myMemoryCache = memory
+
+	if remote == nil {
+		return memory, nil
+	}
+
+	var pool *redis.Pool
+	if c.Config.Cache == "redis" {
+		pool = redisPool
+	}
+
+	myTieredCache, err = cache.NewTieredCache(memory, remote, c.Config.MemoryCache.LocalTTL, pool,
+		c.Config.Redis.Prefix+":cache-invalidate")
+	if err != nil {
+		return nil, err
+	}
+
+	return myTieredCache, nil
+}
+
+// publishCacheStats publishes the stats of the redis, badger or database cache every minute,
+// into the cache itself, so that `celeritas cache:stats` can show what every running instance
+// of the application has seen. The memory cache is private to each process, and not published.
+func (c *Celeritas) publishCacheStats() error {
+	var store interface {
+		cache.Cache
+		Stats() cache.Stats
+	}
+	switch c.Config.Cache {
+	case "redis":
+		store = myRedisCache
+	case "badger":
+		store = myBadgerCache
+	case "database":
+		store = myDatabaseCache
+	default:
+		return nil
+	}
+
+	host, _ := os.Hostname()
+	instance := fmt.Sprintf("%s:%d", host, os.Getpid())
+
+	_, err := c.Scheduler.AddFunc("@every 1m", func() {
+		err := cache.PublishStats(store, instance, store.Stats(), 5*time.Minute)
+		if err != nil {
+			c.Logger.Error("publishing cache stats", "error", err)
+		}
+	})
+	return err
+}
+
+// OpenCache connects to the redis, badger or database cache set in CACHE, without the local
+// copies kept with CACHE_LOCAL_TTL, for tools such as the cli that work on the cache from
+// outside the application. The memory cache lives inside the application, so it cannot be
+// opened.
+func (c *Celeritas) OpenCache() (cache.Cache, error) {
+	// checked before connecting, so that a bad CACHE_CODEC leaves nothing open
+	_, err := c.cacheCodec()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Config.Cache {
+	case "redis":
+		pool, err := c.createRedisPool()
+		if err != nil {
+			return nil, err
+		}
+		client, err := c.createClientRedisCache(pool)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case "database":
+		db, err := c.OpenDB(c.Config.Database.Type, c.BuildDSN())
+		if err != nil {
+			return nil, err
+		}
+		client, err := c.createClientDatabaseCache(db)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case "badger":
+		conn, err := c.createBadgerConn()
+		if err != nil {
+			return nil, err
+		}
+		client, err := c.createClientBadgerCache(conn)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	return nil, errors.New("the memory cache lives inside the application; CACHE must be redis, badger or database")
 }
 
-func (c *Celeritas) createClientRedisCache() *cache.RedisCache {
+func (c *Celeritas) createClientRedisCache(pool *redis.Pool) (*cache.RedisCache, error) {
+	codec, err := c.cacheCodec()
+	if err != nil {
+		return nil, err
+	}
+
 	cacheClient := cache.RedisCache{
-		Conn:   c.createRedisPool(),
-		Prefix: c.config.redis.prefix,
+		Conn:   pool,
+		Prefix: c.Config.Redis.Prefix,
+		Codec:  codec,
 	}
-	return &cacheClient
+	return &cacheClient, nil
 }
 
-func (c *Celeritas) createClientBadgerCache() *cache.BadgerCache {
+func (c *Celeritas) createClientBadgerCache(conn *badger.DB) (*cache.BadgerCache, error) {
+	codec, err := c.cacheCodec()
+	if err != nil {
+		return nil, err
+	}
+
 	cacheClient := cache.BadgerCache{
-		Conn: c.createBadgerConn(),
+		Conn:   conn,
+		Prefix: c.Config.Badger.Prefix,
+		Codec:  codec,
 	}
-	return &cacheClient
+	return &cacheClient, nil
 }
 
-func (c *Celeritas) createRedisPool() *redis.Pool {
-	return &redis.Pool{
-		MaxIdle:     50,
-		MaxActive:   10000,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp",
-				c.config.redis.host,
-				redis.DialPassword(c.config.redis.password))
-		},
-
-		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
-			_, err := conn.Do("PING")
-			return err
-		},
+func (c *Celeritas) createClientDatabaseCache(db *sql.DB) (*cache.DatabaseCache, error) {
+	codec, err := c.cacheCodec()
+	if err != nil {
+		return nil, err
 	}
+
+	cacheClient := cache.DatabaseCache{
+		DB:     db,
+		DBType: c.Config.Database.Type,
+		Codec:  codec,
+	}
+	return &cacheClient, nil
 }
 
-func (c *Celeritas) createBadgerConn() *badger.DB {
-	db, err := badger.Open(badger.DefaultOptions(c.RootPath + "/tmp/badger"))
+// cacheCodec returns the codec set in CACHE_CODEC. An unknown codec is an error, rather than
+// falling back to gob, so that an application never writes values another reader cannot read.
+func (c *Celeritas) cacheCodec() (cache.Codec, error) {
+	codec, err := cache.ParseCodec(c.Config.CacheCodec)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("CACHE_CODEC: %w", err)
+	}
+	return codec, nil
+}
+
+// createBadgerConn opens the badger database of the cache and sessions, at BADGER_PATH or in memory. With
+// BADGER_ENCRYPT set, its files are encrypted with a key derived from KEY, so a database
+// written with one KEY cannot be opened with another. Badger only lets one process open a
+// database, so this fails while another instance of the application has it open.
+func (c *Celeritas) createBadgerConn() (*badger.DB, error) {
+	return c.openBadger("cache", c.Config.Badger.Path)
+}
+
+// createQueueBadgerConn opens the badger database of the queue, alongside the cache's, at
+// BADGER_PATH with -queue added, and with the same BADGER_IN_MEMORY and BADGER_ENCRYPT settings
+func (c *Celeritas) createQueueBadgerConn() (*badger.DB, error) {
+	return c.openBadger("queue", c.Config.Badger.Path+"-queue")
+}
+
+// openBadger opens the badger database at path, relative to the application unless it is
+// absolute. Each database's encryption key is derived from KEY and name.
+func (c *Celeritas) openBadger(name, path string) (*badger.DB, error) {
+	cfg := c.Config.Badger
+
+	var opts badger.Options
+	if cfg.InMemory {
+		opts = badger.DefaultOptions("").WithInMemory(true)
+	} else {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.RootPath, path)
+		}
+		opts = badger.DefaultOptions(path)
+	}
+
+	if cfg.Encrypt {
+		key := sha256.Sum256([]byte("celeritas badger " + name + ":" + c.Config.Key))
+		opts = opts.WithEncryptionKey(key[:]).WithIndexCacheSize(64 << 20)
+	}
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("opening the badger %s: %w", name, err)
 	}
-	return db
+	return db, nil
 }
 
 // BuildDSN builds the datasource name for our database, and returns it as a string
 func (c *Celeritas) BuildDSN() string {
 	var dsn string
+	db := c.Config.Database
 
-	switch os.Getenv("DATABASE_TYPE") {
+	switch db.Type {
 	case "postgres", "postgresql":
-		dsn = fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=%s timezone=UTC connect_timeout=5",
-			os.Getenv("DATABASE_HOST"),
-			os.Getenv("DATABASE_PORT"),
-			os.Getenv("DATABASE_USER"),
-			os.Getenv("DATABASE_NAME"),
-			os.Getenv("DATABASE_SSL_MODE"))
+		port := db.Port
+		if port == 0 {
+			port = 5432
+		}
+
+		dsn = fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=%s timezone=UTC connect_timeout=5",
+			db.Host,
+			port,
+			db.User,
+			db.Name,
+			db.SSLMode)
 
 		// we check to see if a database passsword has been supplied, since including "password=" with nothing
 		// after it sometimes causes postgres to fail to allow a connection.
-		if os.Getenv("DATABASE_PASS") != "" {
-			dsn = fmt.Sprintf("%s password=%s", dsn, os.Getenv("DATABASE_PASS"))
+		if db.Password != "" {
+			dsn = fmt.Sprintf("%s password=%s", dsn, db.Password)
+		}
+
+	case "mysql", "mariadb":
+		port := db.Port
+		if port == 0 {
+			port = 3306
 		}
 
+		cfg := mysql.NewConfig()
+		cfg.User = db.User
+		cfg.Passwd = db.Password
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(db.Host, strconv.Itoa(port))
+		cfg.DBName = db.Name
+		cfg.ParseTime = db.ParseTime
+		cfg.TLSConfig = mysqlTLSMode(db.SSLMode)
+		cfg.Timeout = 5 * time.Second
+		cfg.Params = map[string]string{"charset": db.Charset}
+
+		dsn = cfg.FormatDSN()
+
+	case "sqlite", "sqlite3":
+		// foreign keys are off by default in sqlite, and the busy timeout stops concurrent
+		// writers from failing immediately with "database is locked"
+		dsn = fmt.Sprintf("%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL", c.sqlitePath())
+
 	default:
 
 	}
 
 	return dsn
 }
+
+// sqlitePath returns the location of the sqlite database file. DATABASE_NAME may be an absolute
+// path; otherwise the file lives in db-data/sqlite under the application root, alongside the data
+// volumes used by docker compose. If DATABASE_NAME has no extension, .db is added.
+func (c *Celeritas) sqlitePath() string {
+	name := c.Config.Database.Name
+	if name == "" {
+		name = "celeritas"
+	}
+
+	if name == ":memory:" {
+		return name
+	}
+
+	if filepath.Ext(name) == "" {
+		name = name + ".db"
+	}
+
+	if filepath.IsAbs(name) {
+		return name
+	}
+
+	return filepath.Join(c.RootPath, "db-data", "sqlite", name)
+}
+
+// mysqlTLSMode translates DATABASE_SSL_MODE into a value for the mysql driver's tls parameter.
+// Postgres style modes are accepted, so that one .env setting works for both databases;
+// anything else (true, false, skip-verify, preferred, or the name of a registered tls config)
+// is passed through unchanged.
+func mysqlTLSMode(sslMode string) string {
+	switch strings.ToLower(sslMode) {
+	case "", "disable":
+		return "false"
+	case "allow", "prefer":
+		return "preferred"
+	case "require":
+		return "skip-verify"
+	case "verify-ca", "verify-full":
+		return "true"
+	default:
+		return sslMode
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 func doAuth() error {
 	// migrations
-	dbType := cel.DB.DataType
+	dbType := templateDBType()
 	fileName := fmt.Sprintf("%d_create_auth_tables", time.Now().UnixMicro())
 	upFile := cel.RootPath + "/migrations/" + fileName + ".up.sql"
 	downFile := cel.RootPath + "/migrations/" + fileName + ".down.sql"
@@ -19,7 +19,13 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
-	err = copyDataToFile([]byte("drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens;"), downFile)
+	dropTables := "drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens;"
+	if dbType == "sqlite" {
+		// sqlite has no cascade on drop, so child tables have to go first
+		dropTables = "drop table if exists remember_tokens; drop table if exists tokens; drop table if exists users;"
+	}
+
+	err = copyDataToFile([]byte(dropTables), downFile)
 	if err != nil {
 		exitGracefully(err)
 	}
@@ -91,11 +97,22 @@ func doAuth() error {
 		exitGracefully(err)
 	}
 
+	err = copyFilefromTemplate("templates/views/sessions.jet", cel.RootPath+"/views/sessions.jet")
+	if err != nil {
+		exitGracefully(err)
+	}
+
 	color.Yellow("  - users, tokens, and remember_tokens migrations created and executed")
 	color.Yellow("  - user and token models created")
 	color.Yellow("  - auth middleware created")
+	color.Yellow("  - sessions page created, listing the devices a user is logged in on")
 	color.Yellow("")
 	color.Yellow("Don't forget to add user and token models in data/models.go, and to add appropriate middleware to your routes!")
+	color.Yellow("The sessions page needs these routes:")
+	color.Yellow(`  a.get("/users/sessions", a.Handlers.Sessions)`)
+	color.Yellow(`  a.post("/users/sessions/revoke", a.Handlers.PostRevokeSession)`)
+	color.Yellow(`  a.post("/users/sessions/revoke-others", a.Handlers.PostRevokeOtherSessions)`)
+	color.Yellow("If your sessions table was made by an earlier version of celeritas, run celeritas make user-sessions to index them.")
 
 	return nil
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/tschenhau/celeritas/cache"
+)
+
+func doCacheTable() error {
+	dbType := templateDBType()
+
+	fileName := fmt.Sprintf("%d_create_cache_table", time.Now().UnixMicro())
+
+	upFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFilefromTemplate("templates/migrations/"+dbType+"_cache.sql", upFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataToFile([]byte("drop table cache"), downFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	return nil
+}
+
+// inspectable is implemented by the redis, badger and database caches
+type inspectable interface {
+	Stats() cache.Stats
+	Info() (cache.Info, error)
+}
+
+// doCache runs the cache command for the cache set in CACHE: stats, get, forget or clear
+func doCache(command, arg string) error {
+	c, err := cel.OpenCache()
+	if err != nil {
+		return err
+	}
+	defer closeCache(c)
+
+	switch command {
+	case "stats":
+		return doCacheStats(c)
+
+	case "get":
+		if arg == "" {
+			return errors.New("cache:get requires a key")
+		}
+
+		value, err := c.Get(arg)
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return fmt.Errorf("nothing is cached under %q", arg)
+		}
+		if err != nil {
+			return err
+		}
+
+		color.Yellow("%s (%T):", arg, value)
+		fmt.Printf("%v\n", value)
+
+	case "forget":
+		if arg == "" {
+			return errors.New("cache:forget requires a key")
+		}
+		return c.Forget(arg)
+
+	case "clear":
+		if arg == "" {
+			return c.Empty()
+		}
+		return c.EmptyByMatch(arg)
+	}
+
+	return nil
+}
+
+// doCacheStats prints what the store behind c reports, and the hits, misses and latency that
+// each running instance of the application published in the last few minutes. The cli's own
+// Stats only count what it did, so they are not printed.
+func doCacheStats(c cache.Cache) error {
+	store, ok := c.(inspectable)
+	if !ok {
+		return fmt.Errorf("the %s cache does not report stats", cel.Config.Cache)
+	}
+
+	info, err := store.Info()
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("%s cache:", cel.Config.Cache)
+	fmt.Printf("  %-20s %d\n", "keys", info.Keys)
+
+	names := make([]string, 0, len(info.Server))
+	for name := range info.Server {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %-20s %s\n", name, info.Server[name])
+	}
+
+	published, err := cache.ReadStats(c)
+	if err != nil {
+		return err
+	}
+
+	if len(published) == 0 {
+		color.Yellow("no instance of the application has published its stats yet; they are published every minute")
+		return nil
+	}
+
+	instances := make([]string, 0, len(published))
+	for instance := range published {
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+
+	for _, instance := range instances {
+		s := published[instance]
+		color.Yellow("%s, as of %s:", instance, s.Published.Format(time.DateTime))
+		fmt.Printf("  %-20s %d\n", "hits", s.Hits)
+		fmt.Printf("  %-20s %d\n", "misses", s.Misses)
+		fmt.Printf("  %-20s %.1f%%\n", "hit ratio", s.HitRatio()*100)
+		fmt.Printf("  %-20s %d\n", "writes", s.Writes)
+		fmt.Printf("  %-20s %d\n", "errors", s.Errors)
+		fmt.Printf("  %-20s %s\n", "average latency", s.AverageLatency())
+	}
+
+	return nil
+}
+
+// closeCache closes the connection the cli opened, so that badger writes its files
+func closeCache(c cache.Cache) {
+	switch store := c.(type) {
+	case *cache.RedisCache:
+		_ = store.Conn.Close()
+	case *cache.BadgerCache:
+		_ = store.Conn.Close()
+	case *cache.DatabaseCache:
+		_ = store.DB.Close()
+	}
+}
@@ -7,54 +7,99 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/joho/godotenv"
+	"github.com/go-sql-driver/mysql"
+	"github.com/tschenhau/celeritas"
 )
 
 func setup(arg1, arg2 string) {
 	if arg1 != "new" && arg1 != "version" && arg1 != "help" {
-		err := godotenv.Load()
+		path, err := os.Getwd()
 		if err != nil {
 			exitGracefully(err)
 		}
 
-		path, err := os.Getwd()
+		// the cli only needs the settings for the command it runs, so the config is not validated here
+		cfg, err := celeritas.LoadConfig(path)
 		if err != nil {
 			exitGracefully(err)
 		}
 
+		cel.Config = cfg
 		cel.RootPath = path
-		cel.DB.DataType = os.Getenv("DATABASE_TYPE")
+		cel.DB.DataType = cfg.Database.Type
+	}
+}
+
+// templateDBType returns the database type used to pick migration templates, folding
+// aliases such as mariadb and postgresql into the name of the template that serves them
+func templateDBType() string {
+	switch cel.DB.DataType {
+	case "mariadb":
+		return "mysql"
+	case "pgx", "postgresql":
+		return "postgres"
+	case "sqlite3":
+		return "sqlite"
+	default:
+		return cel.DB.DataType
 	}
 }
 
 func getDSN() string {
-	dbType := cel.DB.DataType
+	dbType := templateDBType()
 
-	if dbType == "pgx" {
-		dbType = "postgres"
-	}
+	switch dbType {
+	case "postgres":
+		db := cel.Config.Database
+		if db.Port == 0 {
+			db.Port = 5432
+		}
 
-	if dbType == "postgres" {
 		var dsn string
-		if os.Getenv("DATABASE_PASS") != "" {
-			dsn = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-				os.Getenv("DATABASE_USER"),
-				os.Getenv("DATABASE_PASS"),
-				os.Getenv("DATABASE_HOST"),
-				os.Getenv("DATABASE_PORT"),
-				os.Getenv("DATABASE_NAME"),
-				os.Getenv("DATABASE_SSL_MODE"))
+		if db.Password != "" {
+			dsn = fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
+				db.User,
+				db.Password,
+				db.Host,
+				db.Port,
+				db.Name,
+				db.SSLMode)
 		} else {
-			dsn = fmt.Sprintf("postgres://%s@%s:%s/%s?sslmode=%s",
-				os.Getenv("DATABASE_USER"),
-				os.Getenv("DATABASE_HOST"),
-				os.Getenv("DATABASE_PORT"),
-				os.Getenv("DATABASE_NAME"),
-				os.Getenv("DATABASE_SSL_MODE"))
+			dsn = fmt.Sprintf("postgres://%s@%s:%d/%s?sslmode=%s",
+				db.User,
+				db.Host,
+				db.Port,
+				db.Name,
+				db.SSLMode)
 		}
 		return dsn
+
+	case "mysql":
+		// golang-migrate strips the scheme and hands the rest to the go-sql-driver parser;
+		// migrations such as the auth and queue tables hold more than one statement
+		cfg, err := mysql.ParseDSN(cel.BuildDSN())
+		if err != nil {
+			exitGracefully(err)
+		}
+		cfg.MultiStatements = true
+
+		// golang-migrate reads any tls value other than a boolean or skip-verify as the name of
+		// a tls config to build from its x-tls-ca parameter, so preferred, which the app uses
+		// for the allow and prefer ssl modes, is dropped in favour of a plain connection
+		if cfg.TLSConfig == "preferred" {
+			cfg.TLSConfig = "false"
+		}
+
+		return "mysql://" + cfg.FormatDSN()
+
+	case "sqlite":
+		return "sqlite3://" + cel.BuildDSN()
+
+	default:
+		exitGracefully(fmt.Errorf("unsupported DATABASE_TYPE %q", dbType))
 	}
-	return "mysql://" + cel.BuildDSN()
+
+	return ""
 }
 
 func showHelp() {
@@ -66,11 +111,19 @@ func showHelp() {
 	migrate down          - reverses the most recent migration
 	migrate reset         - runs all down migrations in reverse order, and then all up migrations
 	make migration <name> - creates two new up and down migrations in the migrations folder
-	make auth             - creates and runs migrations for authentication tables, and creates models and middleware
+	make auth             - creates and runs migrations for authentication tables, and creates models, middleware and views
 	make handler <name>   - creates a stub handler in the handlers directory
 	make model <name>     - creates a new model in the data directory
-	make session          - creates a table in the database as a session store
+	make session          - creates the tables in the database for a session store, and the index of each user's sessions
+	make user-sessions    - creates the index of each user's sessions, for a sessions table made without it
 	make mail <name>      - creates two starter mail templates in the mail directory
+	make queue-tables     - creates the jobs and failed_jobs tables used by the database queue
+	make cache-table      - creates the cache table used by the database cache
+	queue:work [queues]   - runs queue workers for a comma separated list of queues, or QUEUE_NAME
+	cache:stats           - shows how many entries the redis, badger or database cache holds, what the store reports, and each running instance's hits, misses and latency
+	cache:get <key>       - prints the value cached under key
+	cache:forget <key>    - removes the value cached under key
+	cache:clear [prefix]  - removes every cached value, or those whose keys start with prefix
 	
 	`)
 }
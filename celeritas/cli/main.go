@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"os"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/tschenhau/celeritas"
@@ -53,6 +54,19 @@ func main() {
 			exitGracefully(err)
 		}
 
+	case "queue:work":
+		err = doQueueWork(arg2)
+		if err != nil {
+			exitGracefully(err)
+		}
+		message = "Queue worker stopped"
+
+	case "cache:stats", "cache:get", "cache:forget", "cache:clear":
+		err = doCache(strings.TrimPrefix(arg1, "cache:"), arg2)
+		if err != nil {
+			exitGracefully(err)
+		}
+
 	default:
 		showHelp()
 	}
@@ -20,7 +20,7 @@ func doMake(arg2, arg3 string) error {
 		color.Yellow("32 character encryption key: %s", rnd)
 
 	case "migration":
-		dbType := cel.DB.DataType
+		dbType := templateDBType()
 		if arg3 == ""{
 			exitGracefully(errors.New("you must give the migration a name"))
 		}
@@ -128,6 +128,24 @@ func doMake(arg2, arg3 string) error {
 		if err != nil {
 			exitGracefully(err)
 		}
+
+	case "user-sessions":
+		err := doUserSessionsTable()
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "queue-tables":
+		err := doQueueTables()
+		if err != nil {
+			exitGracefully(err)
+		}
+
+	case "cache-table":
+		err := doCacheTable()
+		if err != nil {
+			exitGracefully(err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func doQueueTables() error {
+	dbType := templateDBType()
+
+	fileName := fmt.Sprintf("%d_create_queue_tables", time.Now().UnixMicro())
+
+	upFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFilefromTemplate("templates/migrations/"+dbType+"_queue.sql", upFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataToFile([]byte("drop table if exists failed_jobs; drop table if exists jobs;"), downFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	return nil
+}
+
+// doQueueWork builds the application in the current directory and runs it with
+// "queue:work" as its first argument, followed by the queues to work, if any were
+// given as a comma separated list. SIGINT and SIGTERM are passed on to the worker,
+// so that it can finish the jobs it is running before it exits.
+func doQueueWork(queues string) error {
+	bin := filepath.Join(cel.RootPath, "tmp", "queue-worker")
+
+	build := exec.Command("go", "build", "-o", bin, ".")
+	build.Dir = cel.RootPath
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	err := build.Run()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"queue:work"}
+	if queues != "" {
+		args = append(args, strings.Split(queues, ",")...)
+	}
+
+	worker := exec.Command(bin, args...)
+	worker.Dir = cel.RootPath
+	worker.Stdin = os.Stdin
+	worker.Stdout = os.Stdout
+	worker.Stderr = os.Stderr
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	err = worker.Start()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for sig := range sigs {
+			_ = worker.Process.Signal(sig)
+		}
+	}()
+
+	return worker.Wait()
+}
@@ -5,20 +5,11 @@ import (
 	"time"
 )
 
-
-
 func doSessionTable() error {
-	dbType := cel.DB.DataType
+	dbType := templateDBType()
 
-	if dbType == "mariadb" {
-		dbType = "mysql"
-	}
-
-	if dbType == "postgresql" {
-		dbType = "postgres"
-	}
-
-	fileName := fmt.Sprintf("%d_create_sessions_table", time.Now().UnixMicro())
+	version := time.Now().UnixMicro()
+	fileName := fmt.Sprintf("%d_create_sessions_table", version)
 
 	upFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
 	downFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
@@ -33,10 +24,43 @@ func doSessionTable() error {
 		exitGracefully(err)
 	}
 
+	makeUserSessionsMigration(dbType, version+1)
+
 	err = doMigrate("up", "")
 	if err != nil {
 		exitGracefully(err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// doUserSessionsTable creates the user_sessions table, which indexes each user's sessions, for
+// applications whose sessions table was made before `make session` created it as well
+func doUserSessionsTable() error {
+	makeUserSessionsMigration(templateDBType(), time.Now().UnixMicro())
+
+	err := doMigrate("up", "")
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	return nil
+}
+
+// makeUserSessionsMigration writes the migration for the user_sessions table, numbered version
+func makeUserSessionsMigration(dbType string, version int64) {
+	fileName := fmt.Sprintf("%d_create_user_sessions_table", version)
+
+	upFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
+	downFile := cel.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+
+	err := copyFilefromTemplate("templates/migrations/"+dbType+"_user_sessions.sql", upFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataToFile([]byte("drop table user_sessions"), downFile)
+	if err != nil {
+		exitGracefully(err)
+	}
+}
@@ -0,0 +1,7 @@
+-- CREATE TABLE `some_table` (
+--     `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+--     `some_field` varchar(255) NOT NULL,
+--     `created_at` timestamp NULL DEFAULT NULL,
+--     `updated_at` timestamp NULL DEFAULT NULL ON UPDATE current_timestamp(),
+--     PRIMARY KEY (`id`)
+-- ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
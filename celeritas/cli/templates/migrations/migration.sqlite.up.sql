@@ -0,0 +1,15 @@
+-- CREATE TABLE some_table (
+--     id INTEGER PRIMARY KEY AUTOINCREMENT,
+--     some_field VARCHAR(255) NOT NULL,
+--     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+--     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+-- );
+
+-- keep updated_at current. If you don't need this, delete the next 7 lines
+-- CREATE TRIGGER some_table_set_timestamp
+--     AFTER UPDATE ON some_table
+--     FOR EACH ROW
+--     WHEN NEW.updated_at = OLD.updated_at
+-- BEGIN
+--     UPDATE some_table SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+-- END;
\ No newline at end of file
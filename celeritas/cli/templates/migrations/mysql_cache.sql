@@ -0,0 +1,7 @@
+CREATE TABLE cache (
+	cache_key VARCHAR(255) PRIMARY KEY,
+	value LONGBLOB NOT NULL,
+	expires_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX cache_expires_at_idx ON cache (expires_at);
@@ -0,0 +1,23 @@
+CREATE TABLE jobs (
+	id VARCHAR(64) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	payload LONGTEXT NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL DEFAULT 1,
+	available_at BIGINT NOT NULL,
+	reserved_until BIGINT NULL,
+	created_at BIGINT NOT NULL
+);
+
+CREATE INDEX jobs_queue_available_at_idx ON jobs (queue, available_at);
+
+CREATE TABLE failed_jobs (
+	id VARCHAR(64) PRIMARY KEY,
+	queue VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	payload LONGTEXT NOT NULL,
+	attempts INT NOT NULL,
+	error TEXT NOT NULL,
+	failed_at BIGINT NOT NULL
+);
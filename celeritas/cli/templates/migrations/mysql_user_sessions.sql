@@ -0,0 +1,9 @@
+CREATE TABLE user_sessions (
+	token CHAR(43) PRIMARY KEY,
+	user_id INT NOT NULL,
+	ip_address VARCHAR(255) NOT NULL DEFAULT '',
+	user_agent VARCHAR(255) NOT NULL DEFAULT '',
+	last_activity BIGINT NOT NULL
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id);
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE cache (
+	cache_key character varying(255) PRIMARY KEY,
+	value bytea NOT NULL,
+	expires_at bigint NOT NULL DEFAULT 0
+);
+
+CREATE INDEX cache_expires_at_idx ON cache (expires_at);
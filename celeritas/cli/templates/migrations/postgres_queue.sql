@@ -0,0 +1,23 @@
+CREATE TABLE jobs (
+	id character varying(64) PRIMARY KEY,
+	queue character varying(255) NOT NULL,
+	name character varying(255) NOT NULL,
+	payload text NOT NULL,
+	attempts integer NOT NULL DEFAULT 0,
+	max_attempts integer NOT NULL DEFAULT 1,
+	available_at bigint NOT NULL,
+	reserved_until bigint,
+	created_at bigint NOT NULL
+);
+
+CREATE INDEX jobs_queue_available_at_idx ON jobs (queue, available_at);
+
+CREATE TABLE failed_jobs (
+	id character varying(64) PRIMARY KEY,
+	queue character varying(255) NOT NULL,
+	name character varying(255) NOT NULL,
+	payload text NOT NULL,
+	attempts integer NOT NULL,
+	error text NOT NULL,
+	failed_at bigint NOT NULL
+);
@@ -0,0 +1,7 @@
+CREATE TABLE cache (
+	cache_key TEXT PRIMARY KEY,
+	value BLOB NOT NULL,
+	expires_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX cache_expires_at_idx ON cache (expires_at);
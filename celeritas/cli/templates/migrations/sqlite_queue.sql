@@ -0,0 +1,23 @@
+CREATE TABLE jobs (
+	id TEXT PRIMARY KEY,
+	queue TEXT NOT NULL,
+	name TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL DEFAULT 1,
+	available_at INTEGER NOT NULL,
+	reserved_until INTEGER,
+	created_at INTEGER NOT NULL
+);
+
+CREATE INDEX jobs_queue_available_at_idx ON jobs (queue, available_at);
+
+CREATE TABLE failed_jobs (
+	id TEXT PRIMARY KEY,
+	queue TEXT NOT NULL,
+	name TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	error TEXT NOT NULL,
+	failed_at INTEGER NOT NULL
+);
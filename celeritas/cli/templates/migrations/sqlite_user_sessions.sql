@@ -0,0 +1,9 @@
+CREATE TABLE user_sessions (
+	token TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	ip_address TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	last_activity INTEGER NOT NULL
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id);
\ No newline at end of file
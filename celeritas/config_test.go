@@ -0,0 +1,519 @@
+package celeritas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/tschenhau/celeritas/cache"
+	"github.com/tschenhau/celeritas/mailer"
+	"github.com/tschenhau/celeritas/session"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("PORT", "8080")
+	t.Setenv("DEBUG", "true")
+	t.Setenv("SHUTDOWN_TIMEOUT", "1m30s")
+	t.Setenv("COOKIE_PERSIST", "true")
+	t.Setenv("DATABASE_TYPE", "sqlite")
+	t.Setenv("DATABASE_PORT", "")
+	t.Setenv("PREVIOUS_KEYS", "first, ,second")
+	t.Setenv("SESSION_TYPE", "Redis")
+	t.Setenv("CACHE", "BADGER")
+	t.Setenv("CACHE_CODEC", "JSON")
+	t.Setenv("MAILER_API", "Mailgun")
+	t.Setenv("MAIL_RENDERER", "Jet")
+	t.Setenv("LOG_OUTPUT", "FILE")
+
+	cfg := ConfigFromEnv()
+
+	if cfg.Port != 8080 {
+		t.Errorf("expected port 8080, got %d", cfg.Port)
+	}
+
+	if !cfg.Debug {
+		t.Error("expected debug to be true")
+	}
+
+	if cfg.ShutdownTimeout != 90*time.Second {
+		t.Errorf("expected shutdown timeout of 90s, got %s", cfg.ShutdownTimeout)
+	}
+
+	if !cfg.Cookie.Persist {
+		t.Error("expected cookie persist to be true")
+	}
+
+	if cfg.Database.Type != "sqlite" || cfg.Database.Port != 0 {
+		t.Errorf("wrong database config: %+v", cfg.Database)
+	}
+
+	// driver names and modes are matched exactly, so they are read in lower case
+	if cfg.SessionType != "redis" || cfg.Cache != "badger" || cfg.CacheCodec != "json" {
+		t.Errorf("expected lower case driver names, got %q, %q and %q", cfg.SessionType, cfg.Cache, cfg.CacheCodec)
+	}
+	if cfg.Mail.API != "mailgun" || cfg.Mail.Renderer != "jet" || cfg.Log.Output != "file" {
+		t.Errorf("expected lower case mail and log settings, got %+v and %+v", cfg.Mail, cfg.Log)
+	}
+
+	if len(cfg.PreviousKeys) != 2 || cfg.PreviousKeys[0] != "first" || cfg.PreviousKeys[1] != "second" {
+		t.Errorf("wrong previous keys: %q", cfg.PreviousKeys)
+	}
+
+	// unset values fall back to the defaults
+	if cfg.Renderer != "jet" || cfg.Cookie.Lifetime != 60 || cfg.Database.Charset != "utf8mb4" || DefaultConfig().SessionType != "memory" {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+
+	err := cfg.Validate()
+	if err != nil {
+		t.Errorf("default config with a key should be valid, got %s", err)
+	}
+
+	// values are compared exactly, as the code using them does, until they are normalized
+	mixed := cfg
+	mixed.Cache = "Memory"
+	if err := mixed.Validate(); err == nil || !strings.Contains(err.Error(), "CACHE must be") {
+		t.Errorf("expected CACHE=Memory to be reported before normalizing, got %v", err)
+	}
+	mixed.Normalize()
+	if err := mixed.Validate(); err != nil {
+		t.Errorf("expected a normalized config to be valid, got %s", err)
+	}
+
+	t.Setenv("PORT", "eighty")
+	t.Setenv("DEBUG", "maybe")
+	t.Setenv("KEY", "short")
+	t.Setenv("DATABASE_TYPE", "oracle")
+	t.Setenv("SESSION_TYPE", "redis")
+	t.Setenv("REDIS_HOST", "")
+	t.Setenv("MAILER_API", "pigeon")
+	t.Setenv("DKIM_PRIVATE_KEY", "dkim.pem")
+	t.Setenv("CACHE_CODEC", "protobuf")
+	t.Setenv("CACHE", "badger")
+	t.Setenv("BADGER_GC_SCHEDULE", "whenever")
+	t.Setenv("BADGER_GC_RATIO", "2")
+	t.Setenv("PREVIOUS_KEYS", "short")
+
+	err = ConfigFromEnv().Validate()
+
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a *ConfigError, got %v", err)
+	}
+
+	expected := []string{"PORT", "DEBUG", "KEY must be exactly 32", "DATABASE_TYPE", "REDIS_HOST", "MAILER_API", "DKIM_SELECTOR", "CACHE_CODEC",
+		"BADGER_GC_SCHEDULE", "BADGER_GC_RATIO", "PREVIOUS_KEYS"}
+	for _, want := range expected {
+		found := false
+		for _, p := range cfgErr.Problems {
+			if strings.HasPrefix(p, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected a problem about %s, got %v", want, cfgErr.Problems)
+		}
+	}
+}
+
+func TestCeleritas_NewWithConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AppName = "test"
+	cfg.Port = 4321
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Cookie.Secure = true
+	cfg.Database = DatabaseConfig{Type: "sqlite", Name: "test"}
+
+	var c Celeritas
+	err := c.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.DB.Pool.Close()
+
+	if c.AppName != "test" || c.Server.Port != "4321" || c.EncryptionKey != cfg.Key {
+		t.Errorf("config not applied: %s %s %s", c.AppName, c.Server.Port, c.EncryptionKey)
+	}
+
+	if !c.Session.Cookie.Secure {
+		t.Error("expected session cookie to be secure")
+	}
+
+	err = c.DB.Pool.Ping()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// without CACHE, the application still gets a cache, held in memory
+	if _, ok := c.Cache.(*cache.MemoryCache); !ok {
+		t.Fatalf("expected a memory cache, got %T", c.Cache)
+	}
+
+	if err := c.Cache.Set("foo", "bar"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCeleritas_NewWithConfig_Normalized(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Cache = "Memory"
+	cfg.CacheCodec = "JSON"
+	cfg.Mail.Renderer = "Jet"
+	cfg.Log.Output = "FILE"
+
+	root := t.TempDir()
+
+	var c Celeritas
+	err := c.NewWithConfig(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.logFile.Close()
+	}()
+
+	if _, ok := c.Cache.(*cache.MemoryCache); !ok {
+		t.Errorf("expected a memory cache, got %T", c.Cache)
+	}
+	if c.Config.CacheCodec != "json" || c.Mail.Renderer != "jet" {
+		t.Errorf("expected lower case settings to be used, got %q and %q", c.Config.CacheCodec, c.Mail.Renderer)
+	}
+
+	c.Logger.Info("to the file")
+	if _, err := os.Stat(filepath.Join(root, "logs", cfg.Log.File)); err != nil {
+		t.Errorf("expected LOG_OUTPUT=FILE to log to a file: %v", err)
+	}
+}
+
+func TestCeleritas_NewWithConfig_TieredCache(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Cache = "redis"
+	cfg.Redis.Host = s.Addr()
+	cfg.Redis.Prefix = "test"
+	cfg.MemoryCache.LocalTTL = 5
+
+	var c Celeritas
+	err := c.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.Shutdown(context.Background())
+		redisPool, myTieredCache, myMemoryCache = nil, nil, nil
+	}()
+
+	tiered, ok := c.Cache.(*cache.TieredCache)
+	if !ok {
+		t.Fatalf("expected a tiered cache, got %T", c.Cache)
+	}
+
+	if tiered.Pubsub == nil || s.PubSubNumSub("test:cache-invalidate")["test:cache-invalidate"] != 1 {
+		t.Error("tiered cache is not subscribed to invalidations")
+	}
+}
+
+func TestCeleritas_NewWithConfig_DatabaseCache(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Cache = "database"
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "DATABASE_TYPE is required when CACHE is database") {
+		t.Errorf("expected the database cache to need a database, got %v", err)
+	}
+
+	cfg.Database = DatabaseConfig{Type: "sqlite", Name: "test"}
+
+	var c Celeritas
+	err = c.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.DB.Pool.Close()
+		myDatabaseCache = nil
+	}()
+
+	if _, ok := c.Cache.(*cache.DatabaseCache); !ok {
+		t.Fatalf("expected a database cache, got %T", c.Cache)
+	}
+
+	if len(c.Scheduler.Entries()) == 0 {
+		t.Error("expected the cache table to be pruned on a schedule")
+	}
+
+	// create the table the way "celeritas make cache-table" does
+	migration, err := os.ReadFile("cli/templates/migrations/sqlite_cache.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.DB.Pool.Exec(string(migration))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Cache.Set("foo", "bar", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cli reaches the same table through OpenCache
+	opened, err := c.OpenCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer opened.(*cache.DatabaseCache).DB.Close()
+
+	value, err := cache.GetAs[string](opened, "foo")
+	if err != nil || value != "bar" {
+		t.Errorf("expected bar from the opened cache, got %q %v", value, err)
+	}
+
+	// the cli does not validate the config, so an unknown codec is reported when opening
+	c.Config.CacheCodec = "protobuf"
+	if _, err := c.OpenCache(); err == nil || !strings.Contains(err.Error(), "CACHE_CODEC") {
+		t.Errorf("expected an error about CACHE_CODEC, got %v", err)
+	}
+}
+
+func TestCeleritas_NewWithConfig_BadgerCache(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Cache = "badger"
+	cfg.Badger.InMemory = true
+	cfg.Badger.Encrypt = true
+
+	var c Celeritas
+	err := c.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badgerCache, ok := c.Cache.(*cache.BadgerCache)
+	if !ok {
+		t.Fatalf("expected a badger cache, got %T", c.Cache)
+	}
+	if badgerCache.Prefix != "celeritas" || !badgerCache.Conn.Opts().InMemory {
+		t.Errorf("badger settings not applied: prefix %q, in memory %t", badgerCache.Prefix, badgerCache.Conn.Opts().InMemory)
+	}
+
+	err = c.Cache.Set("foo", "bar")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_ = badgerConn.Close()
+	badgerConn, myBadgerCache = nil, nil
+}
+
+func TestCeleritas_NewWithConfig_BadgerCache_OpenError(t *testing.T) {
+	root := t.TempDir()
+
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Cache = "badger"
+	cfg.Badger.Encrypt = true
+
+	var c Celeritas
+	err := c.NewWithConfig(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// badger lets only one process at a time open a database
+	var second Celeritas
+	err = second.NewWithConfig(root, cfg)
+	if err == nil {
+		t.Error("expected an error opening a badger database that is already open")
+	}
+
+	_ = badgerConn.Close()
+	badgerConn, myBadgerCache = nil, nil
+
+	// the files were encrypted with a key derived from the old KEY
+	cfg.Key = strings.Repeat("x", 32)
+
+	var rotated Celeritas
+	err = rotated.NewWithConfig(root, cfg)
+	if err == nil {
+		_ = badgerConn.Close()
+		badgerConn, myBadgerCache = nil, nil
+		t.Error("expected an error opening an encrypted badger database with another KEY")
+	}
+}
+
+func TestCeleritas_NewWithConfig_SessionStores(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.SessionType = "badger"
+	cfg.Badger.InMemory = true
+
+	var c Celeritas
+	err := c.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.UserSessions != nil {
+		t.Error("expected no user sessions for badger sessions")
+	}
+
+	// the badger database is opened for sessions even though the cache is in memory
+	badgerStore, ok := c.Session.Store.(*session.BadgerStore)
+	if !ok || badgerStore.Conn != badgerConn {
+		t.Errorf("expected sessions in the shared badger database, got %T", c.Session.Store)
+	}
+
+	_ = badgerConn.Close()
+	badgerConn = nil
+
+	cfg.SessionType = "cache"
+
+	var cached Celeritas
+	err = cached.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cacheStore, ok := cached.Session.Store.(*session.CacheStore)
+	if !ok || cacheStore.Cache != cached.Cache {
+		t.Errorf("expected sessions in the application's cache, got %T", cached.Session.Store)
+	}
+
+	// cookie sessions are saved into the cookie by SessionLoad
+	cfg.SessionType = "cookie"
+	cfg.PreviousKeys = []string{strings.Repeat("p", 32)}
+
+	var cookies Celeritas
+	err = cookies.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := cookies.SessionLoad(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookies.Session.Put(r.Context(), "userID", 7)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	res := rr.Result()
+	if len(res.Cookies()) != 1 || len(res.Cookies()[0].Value) < 32 {
+		t.Errorf("expected the session in the cookie, got %v", res.Cookies())
+	}
+
+	// cookie sessions are not kept on the server, so they cannot be listed
+	if cookies.UserSessions != nil {
+		t.Error("expected no user sessions for cookie sessions")
+	}
+}
+
+func TestCeleritas_NewWithConfig_Queue(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Queue.Driver = "badger"
+	cfg.Queue.Name = "mail"
+
+	root := t.TempDir()
+
+	var c Celeritas
+	err := c.NewWithConfig(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = queueBadgerConn.Close()
+		queueBadgerConn = nil
+	}()
+
+	// the queue's database is kept beside the badger cache's
+	if _, err := os.Stat(filepath.Join(root, cfg.Badger.Path+"-queue")); err != nil {
+		t.Errorf("expected the queue database at BADGER_PATH-queue: %v", err)
+	}
+
+	err = c.Mail.SendQueued(context.Background(), mailer.Message{To: "me@here.com", Subject: "queued"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := c.Queue.Queue.Size(context.Background(), "mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != 1 {
+		t.Errorf("expected 1 queued message, got %d", size)
+	}
+}
+
+func TestCeleritas_NewWithConfig_Invalid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = -1
+
+	var c Celeritas
+	err := c.NewWithConfig(t.TempDir(), cfg)
+
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a *ConfigError, got %v", err)
+	}
+
+	if len(cfgErr.Problems) != 2 {
+		t.Errorf("expected problems with PORT and KEY, got %v", cfgErr.Problems)
+	}
+}
+
+func TestCeleritas_MailPreview(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+	cfg.Debug = true
+	cfg.Mail.Preview = true
+
+	var c Celeritas
+	err := c.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	c.Routes.ServeHTTP(rr, httptest.NewRequest("GET", "/_mail", nil))
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/_mail/" {
+		t.Errorf("expected a redirect to /_mail/, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = httptest.NewRecorder()
+	c.Routes.ServeHTTP(rr, httptest.NewRequest("GET", "/_mail/", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Templates") {
+		t.Errorf("expected the preview index, got %d", rr.Code)
+	}
+
+	// the previews are only mounted in debug mode
+	cfg.Debug = false
+	var prod Celeritas
+	err = prod.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	prod.Routes.ServeHTTP(rr, httptest.NewRequest("GET", "/_mail/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without debug, got %d", rr.Code)
+	}
+}
@@ -2,16 +2,30 @@ package celeritas
 
 import (
 	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
 
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v5"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-// OpenDB opens a connection to a sql database. dbType must be one of postgres (or pgx).
-// TODO: add support for mysql/mariadb
+// OpenDB opens a connection to a sql database. dbType must be one of postgres (or pgx),
+// mysql (or mariadb), or sqlite (or sqlite3).
 func (c *Celeritas) OpenDB(dbType, dsn string) (*sql.DB, error) {
-	if dbType == "postgres" || dbType == "postgresql" {
+	switch dbType {
+	case "postgres", "postgresql":
 		dbType = "pgx"
+	case "mariadb":
+		dbType = "mysql"
+	case "sqlite", "sqlite3":
+		dbType = "sqlite3"
+		err := ensureSQLiteDir(dsn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	db, err := sql.Open(dbType, dsn)
@@ -27,3 +41,18 @@ func (c *Celeritas) OpenDB(dbType, dsn string) (*sql.DB, error) {
 	return db, nil
 
 }
+
+// ensureSQLiteDir creates the folder that holds a sqlite database file, since sqlite will
+// create the file itself but not any missing parent directories. dsn may be a plain sqlite
+// dsn, or a golang-migrate url starting with sqlite3://.
+func ensureSQLiteDir(dsn string) error {
+	path := strings.TrimPrefix(dsn, "sqlite3://")
+	path = strings.TrimPrefix(path, "file:")
+	path, _, _ = strings.Cut(path, "?")
+
+	if path == "" || strings.HasPrefix(path, ":memory:") {
+		return nil
+	}
+
+	return os.MkdirAll(filepath.Dir(path), 0755)
+}
@@ -0,0 +1,212 @@
+package celeritas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestCeleritas_BuildDSN_MySQL(t *testing.T) {
+	var c Celeritas
+	c.Config.Database = DatabaseConfig{
+		Type:      "mariadb",
+		Host:      "db.example.com",
+		Port:      3307,
+		User:      "mariadb",
+		Password:  "p@ss:word",
+		Name:      "celeritas",
+		SSLMode:   "require",
+		Charset:   "latin1",
+		ParseTime: false,
+	}
+
+	cfg, err := mysql.ParseDSN(c.BuildDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.User != "mariadb" || cfg.Passwd != "p@ss:word" {
+		t.Errorf("wrong credentials in dsn: %s/%s", cfg.User, cfg.Passwd)
+	}
+
+	if cfg.Addr != "db.example.com:3307" || cfg.DBName != "celeritas" {
+		t.Errorf("wrong address or database in dsn: %s/%s", cfg.Addr, cfg.DBName)
+	}
+
+	if cfg.TLSConfig != "skip-verify" {
+		t.Errorf("expected tls mode skip-verify, got %s", cfg.TLSConfig)
+	}
+
+	if cfg.ParseTime {
+		t.Error("parseTime should be false")
+	}
+
+	if cfg.Params["charset"] != "latin1" {
+		t.Errorf("expected charset latin1, got %s", cfg.Params["charset"])
+	}
+}
+
+func TestCeleritas_BuildDSN_MySQLDefaults(t *testing.T) {
+	c := Celeritas{Config: DefaultConfig()}
+	c.Config.Database.Type = "mysql"
+	c.Config.Database.Host = "localhost"
+
+	cfg, err := mysql.ParseDSN(c.BuildDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Addr != "localhost:3306" {
+		t.Errorf("expected default port 3306, got %s", cfg.Addr)
+	}
+
+	if !cfg.ParseTime {
+		t.Error("parseTime should default to true")
+	}
+
+	if cfg.TLSConfig != "false" {
+		t.Errorf("expected tls to be disabled, got %s", cfg.TLSConfig)
+	}
+
+	if cfg.Params["charset"] != "utf8mb4" {
+		t.Errorf("expected charset utf8mb4, got %s", cfg.Params["charset"])
+	}
+}
+
+func TestCeleritas_OpenDB_MySQL(t *testing.T) {
+	c := Celeritas{Config: testMySQLConfig(t)}
+
+	for _, dbType := range []string{"mysql", "mariadb"} {
+		db, err := c.OpenDB(dbType, c.BuildDSN())
+		if err != nil {
+			t.Fatalf("%s: %s", dbType, err)
+		}
+
+		var now time.Time
+		err = db.QueryRow("select now()").Scan(&now)
+		if err != nil {
+			t.Errorf("%s: %s", dbType, err)
+		}
+		_ = db.Close()
+	}
+}
+
+func TestCeleritas_Migrate_MySQL(t *testing.T) {
+	c := Celeritas{RootPath: t.TempDir(), Config: testMySQLConfig(t)}
+	err := os.Mkdir(filepath.Join(c.RootPath, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := "create table widgets (id int not null auto_increment primary key, name varchar(255) not null);\n" +
+		"insert into widgets (name) values ('sprocket');"
+	down := "drop table widgets;"
+	_ = os.WriteFile(filepath.Join(c.RootPath, "migrations", "1_create_widgets.mysql.up.sql"), []byte(up), 0644)
+	_ = os.WriteFile(filepath.Join(c.RootPath, "migrations", "1_create_widgets.mysql.down.sql"), []byte(down), 0644)
+
+	// this is the same dsn the cli builds for golang-migrate
+	dsn := "mysql://" + c.BuildDSN()
+
+	err = c.MigrateUp(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := c.OpenDB("mysql", c.BuildDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var name string
+	err = db.QueryRow("select name from widgets").Scan(&name)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if name != "sprocket" {
+		t.Errorf("expected sprocket, got %s", name)
+	}
+
+	err = c.MigrateDownAll(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec("select * from widgets")
+	if err == nil || !strings.Contains(strings.ToLower(err.Error()), "widgets") {
+		t.Errorf("expected widgets table to be dropped, got %v", err)
+	}
+}
+
+func TestCeleritas_BuildDSN_SQLite(t *testing.T) {
+	c := Celeritas{RootPath: "/srv/myapp"}
+	c.Config.Database = DatabaseConfig{Type: "sqlite", Name: "celeritas"}
+
+	dsn := c.BuildDSN()
+	if !strings.HasPrefix(dsn, "/srv/myapp/db-data/sqlite/celeritas.db?") {
+		t.Errorf("wrong sqlite dsn: %s", dsn)
+	}
+
+	if !strings.Contains(dsn, "_foreign_keys=on") {
+		t.Errorf("foreign keys not enabled in sqlite dsn: %s", dsn)
+	}
+
+	c.Config.Database.Name = "/var/lib/app.sqlite"
+	dsn = c.BuildDSN()
+	if !strings.HasPrefix(dsn, "/var/lib/app.sqlite?") {
+		t.Errorf("absolute sqlite path not honoured: %s", dsn)
+	}
+}
+
+func TestCeleritas_Migrate_SQLite(t *testing.T) {
+	c := Celeritas{RootPath: t.TempDir()}
+	c.Config.Database = DatabaseConfig{Type: "sqlite", Name: "test"}
+
+	err := os.Mkdir(filepath.Join(c.RootPath, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := "create table widgets (id integer primary key autoincrement, name varchar(255) not null);\n" +
+		"insert into widgets (name) values ('sprocket');"
+	down := "drop table widgets;"
+	_ = os.WriteFile(filepath.Join(c.RootPath, "migrations", "1_create_widgets.sqlite.up.sql"), []byte(up), 0644)
+	_ = os.WriteFile(filepath.Join(c.RootPath, "migrations", "1_create_widgets.sqlite.down.sql"), []byte(down), 0644)
+
+	// this is the same dsn the cli builds for golang-migrate; the db-data folder does not exist yet
+	err = c.MigrateUp("sqlite3://" + c.BuildDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := c.OpenDB("sqlite", c.BuildDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var name string
+	err = db.QueryRow("select name from widgets").Scan(&name)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if name != "sprocket" {
+		t.Errorf("expected sprocket, got %s", name)
+	}
+
+	err = c.Steps(-1, "sqlite3://"+c.BuildDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec("select * from widgets")
+	if err == nil {
+		t.Error("expected widgets table to be dropped")
+	}
+}
@@ -0,0 +1,104 @@
+package celeritas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// OnStartup registers a function to be run by ListenAndServe before the web server
+// starts accepting connections. Hooks run in the order in which they were registered,
+// and if any of them returns an error, the server is not started.
+func (c *Celeritas) OnStartup(f func() error) {
+	c.startupHooks = append(c.startupHooks, f)
+}
+
+// OnShutdown registers a function to be run when the application shuts down. Hooks run
+// in the order in which they were registered, after in-flight requests have been drained
+// and before the scheduler, mailer and connection pools are stopped, so it is still safe
+// to use the database, cache and mailer from inside a hook.
+func (c *Celeritas) OnShutdown(f func(context.Context) error) {
+	c.shutdownHooks = append(c.shutdownHooks, f)
+}
+
+// runStartupHooks runs every registered startup hook, stopping at the first error
+func (c *Celeritas) runStartupHooks() error {
+	for _, hook := range c.startupHooks {
+		if err := hook(); err != nil {
+			return fmt.Errorf("startup hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// Shutdown releases everything the application holds open. It runs the registered
+// shutdown hooks, stops the scheduler, waits for queued mail to be sent, and then
+// closes the database, the in-memory caches, the redis and badger connections and the log
+// file, in that order. Every step is attempted even if an earlier one fails; the errors are
+// joined and returned.
+// ListenAndServe calls Shutdown automatically when it receives SIGINT or SIGTERM.
+func (c *Celeritas) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	for _, hook := range c.shutdownHooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook: %w", err))
+		}
+	}
+
+	if c.Scheduler != nil {
+		// Stop returns a context that is done once running jobs have completed
+		select {
+		case <-c.Scheduler.Stop().Done():
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("stopping scheduler: %w", ctx.Err()))
+		}
+	}
+
+	if err := c.Mail.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("draining mail queue: %w", err))
+	}
+
+	if c.DB.Pool != nil {
+		if err := c.DB.Pool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing database: %w", err))
+		}
+	}
+
+	// the tiered cache's subscriber holds a redis connection until it is stopped
+	if myTieredCache != nil {
+		if err := myTieredCache.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing tiered cache: %w", err))
+		}
+	}
+
+	if myMemoryCache != nil {
+		myMemoryCache.Close()
+	}
+
+	if redisPool != nil {
+		if err := redisPool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing redis: %w", err))
+		}
+	}
+
+	if badgerConn != nil {
+		if err := badgerConn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing badger: %w", err))
+		}
+	}
+
+	if queueBadgerConn != nil {
+		if err := queueBadgerConn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing badger queue: %w", err))
+		}
+	}
+
+	if c.logFile != nil {
+		if err := c.logFile.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing log file: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package celeritas
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func newLifecycleApp() *Celeritas {
+	return &Celeritas{
+		InfoLog:         log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+		ErrorLog:        log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime),
+		Scheduler:       cron.New(),
+		ShutdownTimeout: 5 * time.Second,
+	}
+}
+
+func TestCeleritas_Shutdown(t *testing.T) {
+	c := newLifecycleApp()
+
+	var order []string
+	c.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	c.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "second")
+		return errors.New("boom")
+	})
+	c.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "third")
+		return nil
+	})
+
+	err := c.Shutdown(context.Background())
+	if err == nil {
+		t.Error("expected error from failing shutdown hook")
+	}
+
+	if len(order) != 3 || order[0] != "first" || order[1] != "second" || order[2] != "third" {
+		t.Errorf("shutdown hooks ran in wrong order or were skipped: %v", order)
+	}
+}
+
+func TestCeleritas_ListenAndServe_StartupError(t *testing.T) {
+	c := newLifecycleApp()
+
+	shutdownRan := false
+	c.OnStartup(func() error {
+		return errors.New("cannot start")
+	})
+	c.OnShutdown(func(ctx context.Context) error {
+		shutdownRan = true
+		return nil
+	})
+
+	err := c.ListenAndServe()
+	if err == nil {
+		t.Error("expected error when a startup hook fails")
+	}
+
+	if !shutdownRan {
+		t.Error("shutdown hooks did not run after failed startup")
+	}
+}
+
+func TestCeleritas_ListenAndServe_Signal(t *testing.T) {
+	// a zero port lets the operating system pick a free one
+	c := newLifecycleApp()
+
+	started := make(chan struct{})
+	c.OnStartup(func() error {
+		close(started)
+		return nil
+	})
+
+	shutdownRan := make(chan struct{})
+	c.OnShutdown(func(ctx context.Context) error {
+		close(shutdownRan)
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.ListenAndServe()
+	}()
+
+	<-started
+
+	err := syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down after SIGTERM")
+	}
+
+	select {
+	case <-shutdownRan:
+	default:
+		t.Error("shutdown hook did not run")
+	}
+}
@@ -0,0 +1,129 @@
+package celeritas
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// startLoggers creates c.Logger from the log settings in c.Config, and returns the info and error
+// loggers, which are adapters that write through c.Logger at the info and error levels
+func (c *Celeritas) startLoggers() (*log.Logger, *log.Logger, error) {
+	cfg := c.Config.Log
+
+	var out io.Writer = os.Stdout
+	if cfg.Output == "file" || cfg.Output == "both" {
+		err := os.MkdirAll(filepath.Join(c.RootPath, "logs"), 0755)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		file := &lumberjack.Logger{
+			Filename:   filepath.Join(c.RootPath, "logs", cfg.File),
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+		}
+		c.logFile = file
+
+		out = file
+		if cfg.Output == "both" {
+			out = io.MultiWriter(os.Stdout, file)
+		}
+	}
+
+	handler := newLogHandler(out, cfg)
+	c.Logger = slog.New(handler)
+
+	infoLog := slog.NewLogLogger(handler, slog.LevelInfo)
+	errorLog := slog.NewLogLogger(handler, slog.LevelError)
+
+	return infoLog, errorLog, nil
+}
+
+// newLogHandler returns a text or json slog handler writing to w at the configured level
+func newLogHandler(w io.Writer, cfg LogConfig) slog.Handler {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(cfg.Level))
+	if err != nil {
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	if strings.EqualFold(cfg.Format, "json") {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// RequestLogger returns c.Logger with the request ID of r attached, so that log lines written
+// while handling a request can be matched to its access log entry
+func (c *Celeritas) RequestLogger(r *http.Request) *slog.Logger {
+	reqID := middleware.GetReqID(r.Context())
+	if reqID == "" {
+		return c.Logger
+	}
+	return c.Logger.With("request_id", reqID)
+}
+
+// AccessLog is middleware that writes one log line per request, with the request ID, status,
+// size, latency and the id of the logged in user. It must run inside SessionLoad, so that the
+// user id can be read from the session.
+func (c *Celeritas) AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		attrs := []any{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote_ip", r.RemoteAddr),
+		}
+
+		if userID := c.sessionUserID(r); userID != 0 {
+			attrs = append(attrs, slog.Int("user_id", userID))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		c.RequestLogger(r).Log(r.Context(), level, "request", attrs...)
+	})
+}
+
+// sessionUserID returns the userID stored in the session for r, or 0 if there is none
+func (c *Celeritas) sessionUserID(r *http.Request) (userID int) {
+	if c.Session == nil {
+		return 0
+	}
+
+	// scs panics if the session was not loaded for this request
+	defer func() {
+		if recover() != nil {
+			userID = 0
+		}
+	}()
+
+	return c.Session.GetInt(r.Context(), "userID")
+}
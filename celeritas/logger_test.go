@@ -0,0 +1,114 @@
+package celeritas
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/tschenhau/celeritas/session"
+)
+
+func TestCeleritas_AccessLog(t *testing.T) {
+	var buf bytes.Buffer
+
+	c := Celeritas{}
+	c.Logger = slog.New(newLogHandler(&buf, LogConfig{Level: "info", Format: "json"}))
+
+	sess := session.Session{
+		CookieLifetime: "60",
+		CookiePersist:  "true",
+		CookieName:     "celeritas",
+		SessionType:    "memory",
+	}
+	var err error
+	c.Session, err = sess.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Session.Put(r.Context(), "userID", 7)
+		c.RequestLogger(r).Info("inside handler")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	h := middleware.RequestID(c.SessionLoad(c.AccessLog(handler)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/widgets", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d: %s", len(lines), buf.String())
+	}
+
+	var inside, access map[string]interface{}
+	_ = json.Unmarshal([]byte(lines[0]), &inside)
+	err = json.Unmarshal([]byte(lines[1]), &access)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if access["request_id"] == nil || access["request_id"] == "" {
+		t.Error("request id missing from access log")
+	}
+
+	if inside["request_id"] != access["request_id"] {
+		t.Errorf("request ids do not match: %v and %v", inside["request_id"], access["request_id"])
+	}
+
+	if access["status"] != float64(http.StatusCreated) || access["bytes"] != float64(5) {
+		t.Errorf("wrong status or size in access log: %v %v", access["status"], access["bytes"])
+	}
+
+	if access["user_id"] != float64(7) {
+		t.Errorf("expected user_id 7, got %v", access["user_id"])
+	}
+
+	if access["path"] != "/widgets" || access["latency"] == nil {
+		t.Errorf("wrong access log entry: %s", lines[1])
+	}
+}
+
+func TestCeleritas_startLoggers_File(t *testing.T) {
+	c := Celeritas{RootPath: t.TempDir(), Config: DefaultConfig()}
+	c.Config.Log.Output = "file"
+	c.Config.Log.Level = "warn"
+
+	infoLog, errorLog, err := c.startLoggers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infoLog.Println("not written")
+	errorLog.Println("something failed")
+	c.Logger.Warn("careful", "widget", 3)
+
+	err = c.logFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(c.RootPath, "logs", "celeritas.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(content), "not written") {
+		t.Error("info message written below the configured level")
+	}
+
+	if !strings.Contains(string(content), "level=ERROR msg=\"something failed\"") {
+		t.Errorf("error log adapter did not write through the logger: %s", content)
+	}
+
+	if !strings.Contains(string(content), "widget=3") {
+		t.Errorf("structured attributes missing: %s", content)
+	}
+}
@@ -0,0 +1,208 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	sp "github.com/SparkPost/gosparkpost"
+	"github.com/mailgun/mailgun-go/v4"
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go"
+	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// apiTimeout limits how long a single api call may take
+const apiTimeout = 10 * time.Second
+
+// sendMailgun sends env with the mailgun api. APIUrl is the api's base url, such as
+// https://api.eu.mailgun.net, with or without the /v3 suffix.
+func (m *Mail) sendMailgun(env *Envelope) error {
+	mg := mailgun.NewMailgun(m.Domain, m.APIKey)
+
+	base := strings.TrimSuffix(m.APIUrl, "/")
+	if !strings.HasSuffix(base, "/v3") {
+		base += "/v3"
+	}
+	mg.SetAPIBase(base)
+
+	if m.httpClient != nil {
+		mg.SetClient(m.httpClient)
+	}
+
+	message := mg.NewMessage(env.From.String(), env.Subject, env.Plain, addressStrings(env.To)...)
+	message.SetHtml(env.HTML)
+
+	for _, a := range addressStrings(env.CC) {
+		message.AddCC(a)
+	}
+
+	for _, a := range addressStrings(env.BCC) {
+		message.AddBCC(a)
+	}
+
+	if env.ReplyTo != nil {
+		message.SetReplyTo(env.ReplyTo.String())
+	}
+
+	for name, value := range env.Headers {
+		message.AddHeader(name, value)
+	}
+
+	for _, a := range env.Attachments {
+		message.AddBufferAttachment(a.Name, a.Data)
+	}
+
+	// mailgun uses the file name of an inline image as its content id
+	for _, a := range env.Inline {
+		message.AddReaderInline(a.Name, io.NopCloser(bytes.NewReader(a.Data)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	_, _, err := mg.Send(ctx, message)
+	return err
+}
+
+// sendSparkPost sends env with the sparkpost api. Sparkpost has no cc or bcc as such; every
+// address is a recipient, and the To and Cc headers decide how each one sees the message.
+func (m *Mail) sendSparkPost(env *Envelope) error {
+	client := sp.Client{Client: m.httpClient}
+	err := client.Init(&sp.Config{BaseUrl: m.APIUrl, ApiKey: m.APIKey, ApiVersion: 1})
+	if err != nil {
+		return err
+	}
+
+	headerTo := strings.Join(addressStrings(env.To), ", ")
+
+	var recipients []sp.Recipient
+	for _, list := range [][]*netmail.Address{env.To, env.CC, env.BCC} {
+		for _, a := range list {
+			recipients = append(recipients, sp.Recipient{
+				Address: sp.Address{Email: a.Address, Name: a.Name, HeaderTo: headerTo},
+			})
+		}
+	}
+
+	headers := make(map[string]string)
+	for name, value := range env.Headers {
+		headers[name] = value
+	}
+	if len(env.CC) > 0 {
+		headers["CC"] = strings.Join(addressStrings(env.CC), ", ")
+	}
+
+	content := sp.Content{
+		From:    sp.From{Email: env.From.Address, Name: env.From.Name},
+		Subject: env.Subject,
+		HTML:    env.HTML,
+		Text:    env.Plain,
+		Headers: headers,
+	}
+
+	if env.ReplyTo != nil {
+		content.ReplyTo = env.ReplyTo.String()
+	}
+
+	for _, a := range env.Attachments {
+		content.Attachments = append(content.Attachments, sp.Attachment{
+			MIMEType: a.ContentType,
+			Filename: a.Name,
+			B64Data:  base64.StdEncoding.EncodeToString(a.Data),
+		})
+	}
+
+	// sparkpost uses the name of an inline image as its content id
+	for _, a := range env.Inline {
+		content.InlineImages = append(content.InlineImages, sp.InlineImage{
+			MIMEType: a.ContentType,
+			Filename: a.Name,
+			B64Data:  base64.StdEncoding.EncodeToString(a.Data),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	_, _, err = client.SendContext(ctx, &sp.Transmission{Recipients: recipients, Content: content})
+	return err
+}
+
+// sendSendGrid sends env with the sendgrid v3 api. APIUrl is the api's host, such as
+// https://api.sendgrid.com.
+func (m *Mail) sendSendGrid(env *Envelope) error {
+	message := sgmail.NewV3Mail()
+	message.SetFrom(sgmail.NewEmail(env.From.Name, env.From.Address))
+	message.Subject = env.Subject
+
+	p := sgmail.NewPersonalization()
+	p.AddTos(sendGridEmails(env.To)...)
+	p.AddCCs(sendGridEmails(env.CC)...)
+	p.AddBCCs(sendGridEmails(env.BCC)...)
+	message.AddPersonalizations(p)
+
+	// sendgrid requires the plain text content to come first
+	message.AddContent(sgmail.NewContent("text/plain", env.Plain), sgmail.NewContent("text/html", env.HTML))
+
+	if env.ReplyTo != nil {
+		message.SetReplyTo(sgmail.NewEmail(env.ReplyTo.Name, env.ReplyTo.Address))
+	}
+
+	for name, value := range env.Headers {
+		message.SetHeader(name, value)
+	}
+
+	for _, a := range env.Attachments {
+		message.AddAttachment(sgmail.NewAttachment().
+			SetContent(base64.StdEncoding.EncodeToString(a.Data)).
+			SetType(a.ContentType).
+			SetFilename(a.Name).
+			SetDisposition("attachment"))
+	}
+
+	for _, a := range env.Inline {
+		message.AddAttachment(sgmail.NewAttachment().
+			SetContent(base64.StdEncoding.EncodeToString(a.Data)).
+			SetType(a.ContentType).
+			SetFilename(a.Name).
+			SetDisposition("inline").
+			SetContentID(a.Name))
+	}
+
+	request := sendgrid.GetRequest(m.APIKey, "/v3/mail/send", strings.TrimSuffix(m.APIUrl, "/"))
+	request.Method = rest.Post
+	request.Body = sgmail.GetRequestBody(message)
+
+	client := rest.DefaultClient
+	if m.httpClient != nil {
+		client = &rest.Client{HTTPClient: m.httpClient}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	res, err := client.SendWithContext(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("sendgrid: status %d: %s", res.StatusCode, res.Body)
+	}
+
+	return nil
+}
+
+func sendGridEmails(addresses []*netmail.Address) []*sgmail.Email {
+	var emails []*sgmail.Email
+	for _, a := range addresses {
+		emails = append(emails, sgmail.NewEmail(a.Name, a.Address))
+	}
+	return emails
+}
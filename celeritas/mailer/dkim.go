@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/toorop/go-dkim"
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// DKIM holds what is needed to sign outgoing mail. The public key is published in a TXT
+// record at Selector._domainkey.Domain.
+type DKIM struct {
+	PrivateKey []byte
+	Selector   string
+	Domain     string
+}
+
+// dkimHeaders are the headers covered by the signature, when the message has them
+var dkimHeaders = []string{
+	"from", "to", "cc", "reply-to", "subject", "date", "message-id", "mime-version", "content-type",
+}
+
+// LoadDKIM reads a PEM encoded RSA private key, in PKCS #1 or PKCS #8 form, from path
+func LoadDKIM(path, selector, domain string) (*DKIM, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, fmt.Errorf("mailer: no pem encoded key in %s", path)
+	}
+
+	_, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("mailer: reading dkim key %s: %w", path, err)
+		}
+		if _, ok := parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("mailer: dkim key %s is not an rsa key", path)
+		}
+	}
+
+	if selector == "" || domain == "" {
+		return nil, errors.New("mailer: dkim needs a selector and a domain")
+	}
+
+	return &DKIM{PrivateKey: key, Selector: selector, Domain: domain}, nil
+}
+
+// sign adds a DKIM-Signature header to email. Signing renders the message, so it must be
+// the last change made to it.
+func (d *DKIM) sign(email *mail.Email) {
+	email.SetDkim(dkim.SigOptions{
+		Version:               1,
+		PrivateKey:            d.PrivateKey,
+		Domain:                d.Domain,
+		Selector:              d.Selector,
+		Canonicalization:      "relaxed/relaxed",
+		Algo:                  "rsa-sha256",
+		Headers:               append([]string(nil), dkimHeaders...),
+		QueryMethods:          []string{"dns/txt"},
+		AddSignatureTimestamp: true,
+	})
+}
+
+// emailMessage returns the message as it is sent: the signed copy, if email was signed
+func emailMessage(email *mail.Email) string {
+	if email.DkimMsg != "" {
+		return email.DkimMsg
+	}
+	return email.GetMessage()
+}
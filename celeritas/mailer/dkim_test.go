@@ -0,0 +1,136 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toorop/go-dkim"
+)
+
+// newTestDKIM writes a new private key to a file, and returns it loaded, along with a lookup
+// that serves the matching public key as if it were published in dns
+func newTestDKIM(t *testing.T) (*DKIM, dkim.DNSOpt) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dkim.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	err = os.WriteFile(path, keyPEM, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := LoadDKIM(path, "mail", "here.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := dkim.DNSOptLookupTXT(func(name string) ([]string, error) {
+		if name != "mail._domainkey.here.com" {
+			t.Errorf("looked up the wrong record: %s", name)
+		}
+		return []string{"v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(pub)}, nil
+	})
+
+	return d, lookup
+}
+
+func verifyDKIM(t *testing.T, raw string, lookup dkim.DNSOpt) {
+	t.Helper()
+
+	if !strings.HasPrefix(raw, "DKIM-Signature:") {
+		t.Fatalf("message is not signed:\n%.200s", raw)
+	}
+
+	msg := []byte(raw)
+	status, err := dkim.Verify(&msg, lookup)
+	if err != nil || status != dkim.SUCCESS {
+		t.Errorf("signature did not verify: %v %v", status, err)
+	}
+}
+
+func TestMail_DKIM_SMTP(t *testing.T) {
+	d, lookup := newTestDKIM(t)
+
+	m := Mail{
+		Templates:   mailer.Templates,
+		Host:        mailer.Host,
+		Port:        mailer.Port,
+		Encryption:  "none",
+		FromAddress: "me@here.com",
+		FromName:    "Joe",
+		DKIM:        d,
+	}
+
+	err := m.SendSMTPMessage(richMessage("signed@there.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyDKIM(t, sentTo(t, "signed@there.com").Data, lookup)
+}
+
+func TestMail_DKIM_File(t *testing.T) {
+	d, lookup := newTestDKIM(t)
+
+	dir := t.TempDir()
+	m := Mail{
+		Templates:   mailer.Templates,
+		FromAddress: "me@here.com",
+		DKIM:        d,
+		Transport:   &FileTransport{Dir: dir},
+	}
+
+	err := m.Send(Message{To: "you@there.com", Subject: "signed", Template: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v", files)
+	}
+
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyDKIM(t, string(raw), lookup)
+
+	// a changed message no longer verifies
+	tampered := []byte(strings.Replace(string(raw), "Subject: signed", "Subject: changed", 1))
+	status, _ := dkim.Verify(&tampered, lookup)
+	if status == dkim.SUCCESS {
+		t.Error("tampered message verified")
+	}
+}
+
+func TestLoadDKIM_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.pem")
+	_ = os.WriteFile(path, []byte("not a key"), 0600)
+
+	_, err := LoadDKIM(path, "mail", "here.com")
+	if err == nil {
+		t.Error("expected an error for a file without a key")
+	}
+
+	_, err = LoadDKIM(filepath.Join(t.TempDir(), "missing.pem"), "mail", "here.com")
+	if err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
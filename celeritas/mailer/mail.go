@@ -1,14 +1,22 @@
 package mailer
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"html/template"
 	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/http"
+	netmail "net/mail"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	apimail "github.com/ainsleyclark/go-mail"
+	"github.com/CloudyKit/jet/v6"
+	"github.com/tschenhau/celeritas/queue"
 	"github.com/vanng822/go-premailer/premailer"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
@@ -24,22 +32,83 @@ type Mail struct {
 	Encryption  string
 	FromAddress string
 	FromName    string
-	Jobs        chan Message
-	Results     chan Result
-	API         string
-	APIKey      string
-	APIUrl      string
+	// Jobs is the channel read by the workers started by ListenForMail. Use SendAsync or
+	// SendInBackground rather than sending on it directly.
+	Jobs chan Message
+	// Results receives the result of each message sent directly on Jobs.
+	//
+	// Deprecated: a reader may receive the result of another sender's message; use SendAsync,
+	// which returns a channel for the result of each message.
+	Results chan Result
+	API     string
+	APIKey  string
+	APIUrl  string
+	// Renderer is go, for html/template and text/template files, or jet. See buildHTMLMessage.
+	Renderer string
+	// JetViews renders jet mail templates; when nil, a set loading from Templates is used
+	JetViews *jet.Set
+	// Layout is the layout in Templates/layouts that wraps each message, unless the message
+	// names its own
+	Layout string
+	// DKIM, when set, signs every message sent over smtp or written by FileTransport
+	DKIM *DKIM
+	// CacheTemplates keeps parsed templates for the life of the process, instead of parsing
+	// them for every message; it is set when DEBUG is false
+	CacheTemplates bool
+	// Workers is the number of messages ListenForMail sends at the same time. It defaults to 1.
+	Workers int
+	// Logger receives the errors from messages sent with SendInBackground, and from messages
+	// sent on Jobs whose result could not be delivered on Results
+	Logger *slog.Logger
+	// pending counts the messages handed over by SendAsync and SendInBackground that have
+	// not been sent yet, from before they go on Jobs, so that Drain cannot miss a message a
+	// worker has just taken off the channel
+	pending int32
+	queue   *queue.Manager
+	// Transport, when set, delivers every message in place of smtp or an api; see
+	// LogTransport, FileTransport and FakeMailer
+	Transport Transport
+	// httpClient is used by the api transports; nil means http.DefaultClient
+	httpClient *http.Client
 }
 
-// Message is the type for an email message
+// Message is the type for an email message. From and FromName default to the mailer's
+// FromAddress and FromName. Addresses may be plain, or of the form "Name <address>".
 type Message struct {
-	From        string
-	FromName    string
-	To          string
-	Subject     string
-	Template    string
+	From     string
+	FromName string
+	To       string
+	// Recipients are sent the message along with To
+	Recipients []string
+	CC         []string
+	BCC        []string
+	ReplyTo    string
+	Headers    map[string]string
+	Subject    string
+	Template   string
+	// Layout, when set, overrides the mailer's Layout
+	Layout string
+	// Attachments are the paths of files to attach
 	Attachments []string
-	Data        interface{}
+	// Files are attached from memory
+	Files []Attachment
+	// Inline images are shown in the html body, which refers to each one as cid:Name
+	Inline []Attachment
+	Data   interface{}
+
+	// set by SendAsync and SendInBackground, and used by the workers
+	ctx        context.Context
+	result     chan Result
+	background bool
+	counted    bool // pending was incremented when the message was queued
+}
+
+// Attachment is a file attached to a message from memory. ContentType is worked out from
+// Name, or from Data, when it is empty.
+type Attachment struct {
+	Name        string
+	Data        []byte
+	ContentType string
 }
 
 // Result contains information regarding the status of the sent email message
@@ -48,26 +117,145 @@ type Result struct {
 	Error   error
 }
 
-// ListenForMail listens to the mail channel and sends mail
-// when it receives a payload. It runs continually in the background,
-// and sends error/success messages back on the Results channel.
-// Note that if api and api key are set, it will prefer using
-// an api to send mail
+// ListenForMail starts Workers goroutines that read messages from the Jobs channel and send
+// them, and then blocks forever. Each result goes to the channel returned by SendAsync, or
+// is logged for SendInBackground; for a message sent directly on Jobs, it goes to Results if
+// there is room there, and is logged otherwise, so that a missing reader cannot stop the workers.
+// Note that if api and api key are set, it will prefer using an api to send mail
 func (m *Mail) ListenForMail() {
+	workers := m.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range m.Jobs {
+				m.process(msg)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// process sends one message from the Jobs channel and delivers its result
+func (m *Mail) process(msg Message) {
+	if !msg.counted {
+		// sent directly on Jobs, so only counted from here
+		atomic.AddInt32(&m.pending, 1)
+	}
+	defer atomic.AddInt32(&m.pending, -1)
+
+	var res Result
+	if msg.ctx != nil && msg.ctx.Err() != nil {
+		// the sender gave up before a worker got to the message
+		res = Result{false, msg.ctx.Err()}
+	} else if err := m.Send(msg); err != nil {
+		res = Result{false, err}
+	} else {
+		res = Result{true, nil}
+	}
+
+	switch {
+	case msg.result != nil:
+		msg.result <- res
+	case msg.background:
+		if res.Error != nil {
+			m.logger().Error("mailer: sending message", "to", msg.To, "subject", msg.Subject, "error", res.Error)
+		}
+	default:
+		select {
+		case m.Results <- res:
+		default:
+			if res.Error != nil {
+				m.logger().Error("mailer: sending message", "to", msg.To, "subject", msg.Subject, "error", res.Error)
+			}
+		}
+	}
+}
+
+// SendAsync hands msg to the workers started by ListenForMail, and returns a channel that
+// receives its result, and only its result. If ctx is done before a worker picks the message
+// up, it is not sent, and the result holds ctx's error.
+func (m *Mail) SendAsync(ctx context.Context, msg Message) <-chan Result {
+	result := make(chan Result, 1)
+	msg.ctx = ctx
+	msg.result = result
+	msg.counted = true
+
+	atomic.AddInt32(&m.pending, 1)
+	select {
+	case m.Jobs <- msg:
+	case <-ctx.Done():
+		atomic.AddInt32(&m.pending, -1)
+		result <- Result{false, ctx.Err()}
+	}
+
+	return result
+}
+
+// SendInBackground hands msg to the workers started by ListenForMail without waiting for it
+// to be sent. It does not block, even when the workers are busy; errors are logged to Logger.
+func (m *Mail) SendInBackground(msg Message) {
+	msg.background = true
+	msg.counted = true
+
+	atomic.AddInt32(&m.pending, 1)
+	select {
+	case m.Jobs <- msg:
+	default:
+		// every worker is busy and the channel is full, so wait in a goroutine of our own;
+		// the message is already pending, so Drain waits for it
+		go func() {
+			m.Jobs <- msg
+		}()
+	}
+}
+
+func (m *Mail) logger() *slog.Logger {
+	if m.Logger == nil {
+		return slog.Default()
+	}
+	return m.Logger
+}
+
+// Drain blocks until every message waiting on the Jobs channel has been sent and its
+// result delivered, or until ctx is done. It is used when shutting down, so that queued
+// mail is not lost. Messages from SendAsync and SendInBackground are waited for from the
+// moment they are queued; a message sent directly on Jobs may be missed in the moment
+// between a worker taking it and starting to send it.
+func (m *Mail) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
 	for {
-		msg := <-m.Jobs
-		err := m.Send(msg)
-		if err != nil {
-			m.Results <- Result{false, err}
-		} else {
-			m.Results <- Result{true, nil}
+		if len(m.Jobs) == 0 && atomic.LoadInt32(&m.pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
 		}
 	}
 }
 
-// Send sends an email message using correct method. If API values are set,
-// it will send using the appropriate api; otherwise, it sends via smtp
+// Send sends an email message using correct method. If Transport is set, it hands the
+// message to it; if API values are set, it will send using the appropriate api; otherwise,
+// it sends via smtp
 func (m *Mail) Send(msg Message) error {
+	if m.Transport != nil {
+		env, err := m.prepare(msg)
+		if err != nil {
+			return err
+		}
+		return m.Transport.Deliver(env)
+	}
+
 	if len(m.API) > 0 && len(m.APIKey) > 0 && len(m.APIUrl) > 0 && m.API != "smtp" {
 		return m.ChooseAPI(msg)
 	}
@@ -87,93 +275,180 @@ func (m *Mail) ChooseAPI(msg Message) error {
 // SendUsingAPI sends a message using the appropriate API. It can be called directly, if necessary.
 // transport can be one of sparkpost, sendgrid, or mailgun
 func (m *Mail) SendUsingAPI(msg Message, transport string) error {
-	if msg.From == "" {
-		msg.From = m.FromAddress
+	var send func(*Envelope) error
+	switch transport {
+	case "mailgun":
+		send = m.sendMailgun
+	case "sparkpost":
+		send = m.sendSparkPost
+	case "sendgrid":
+		send = m.sendSendGrid
+	default:
+		return fmt.Errorf("unknown api %s; only mailgun, sparkpost or sendgrid accepted", transport)
 	}
 
-	if msg.FromName == "" {
-		msg.FromName = m.FromName
+	env, err := m.prepare(msg)
+	if err != nil {
+		return err
 	}
 
-	cfg := apimail.Config{
-		URL:         m.APIUrl,
-		APIKey:      m.APIKey,
-		Domain:      m.Domain,
-		FromAddress: msg.From,
-		FromName:    msg.FromName,
-	}
+	return send(env)
+}
+
+// Envelope is a message that is ready to send: its templates are rendered, its addresses
+// checked, defaults applied and attachments read, so that every transport sends the same thing
+type Envelope struct {
+	From        *netmail.Address
+	To          []*netmail.Address
+	CC          []*netmail.Address
+	BCC         []*netmail.Address
+	ReplyTo     *netmail.Address
+	Headers     map[string]string
+	Subject     string
+	HTML        string
+	Plain       string
+	Attachments []Attachment
+	Inline      []Attachment
 
-	driver, err := apimail.NewClient(transport, cfg)
+	// dkim signs the message when it is turned into mime
+	dkim *DKIM
+}
+
+// prepare renders msg and checks it, ready for sending by smtp or an api
+func (m *Mail) prepare(msg Message) (*Envelope, error) {
+	var err error
+	env := &Envelope{Subject: msg.Subject, Headers: msg.Headers, dkim: m.DKIM}
+
+	from := msg.From
+	if from == "" {
+		from = m.FromAddress
+	}
+	env.From, err = parseAddress(from)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if env.From.Name == "" {
+		env.From.Name = msg.FromName
+	}
+	if env.From.Name == "" {
+		env.From.Name = m.FromName
 	}
 
-	formattedMessage, err := m.buildHTMLMessage(msg)
-	if err != nil {
-		return err
+	var to []string
+	if msg.To != "" {
+		to = append(to, msg.To)
+	}
+	to = append(to, msg.Recipients...)
+	if len(to) == 0 {
+		return nil, errors.New("mailer: message has no recipients")
 	}
 
-	plainMessage, err := m.buildPlainTextMessage(msg)
+	env.To, err = parseAddresses(to)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	env.CC, err = parseAddresses(msg.CC)
+	if err != nil {
+		return nil, err
+	}
+	env.BCC, err = parseAddresses(msg.BCC)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.ReplyTo != "" {
+		env.ReplyTo, err = parseAddress(msg.ReplyTo)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	tx := &apimail.Transmission{
-		Recipients: []string{msg.To},
-		Subject:    msg.Subject,
-		HTML:       formattedMessage,
-		PlainText:  plainMessage,
+	for name, value := range msg.Headers {
+		if strings.ContainsAny(name, "\r\n:") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("mailer: invalid header %q", name)
+		}
 	}
 
-	// add attachments
-	err = m.addAPIAttachments(msg, tx)
+	env.HTML, err = m.buildHTMLMessage(msg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = driver.Send(tx)
+	env.Plain, err = m.buildPlainTextMessage(msg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
-}
+	for _, x := range msg.Attachments {
+		content, err := ioutil.ReadFile(x)
+		if err != nil {
+			return nil, err
+		}
+		env.Attachments = append(env.Attachments, withContentType(Attachment{Name: filepath.Base(x), Data: content}))
+	}
 
-// addAPIAttachments adds attachments, if any, to mail being sent via api
-func (m *Mail) addAPIAttachments(msg Message, tx *apimail.Transmission) error {
-	if len(msg.Attachments) > 0 {
-		var attachments []apimail.Attachment
+	for _, a := range msg.Files {
+		env.Attachments = append(env.Attachments, withContentType(a))
+	}
 
-		for _, x := range msg.Attachments {
-			var attach apimail.Attachment
-			content, err := ioutil.ReadFile(x)
-			if err != nil {
-				return err
-			}
+	for _, a := range msg.Inline {
+		env.Inline = append(env.Inline, withContentType(a))
+	}
 
-			fileName := filepath.Base(x)
-			attach.Bytes = content
-			attach.Filename = fileName
-			attachments = append(attachments, attach)
+	return env, nil
+}
+
+func parseAddress(address string) (*netmail.Address, error) {
+	a, err := netmail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid address %q: %w", address, err)
+	}
+	return a, nil
+}
+
+func parseAddresses(addresses []string) ([]*netmail.Address, error) {
+	var parsed []*netmail.Address
+	for _, x := range addresses {
+		a, err := parseAddress(x)
+		if err != nil {
+			return nil, err
 		}
+		parsed = append(parsed, a)
+	}
+	return parsed, nil
+}
 
-		tx.Attachments = attachments
+// addressStrings formats addresses for a header
+func addressStrings(addresses []*netmail.Address) []string {
+	var s []string
+	for _, a := range addresses {
+		s = append(s, a.String())
 	}
+	return s
+}
 
-	return nil
+// withContentType fills in a's content type, if it is missing
+func withContentType(a Attachment) Attachment {
+	if a.ContentType == "" {
+		a.ContentType = mime.TypeByExtension(filepath.Ext(a.Name))
+	}
+	if a.ContentType == "" {
+		a.ContentType = http.DetectContentType(a.Data)
+	}
+	return a
 }
 
 // SendSMTPMessage builds and sends an email message using SMTP. This is called by ListenForMail,
 // and can also be called directly when necessary
 func (m *Mail) SendSMTPMessage(msg Message) error {
-	formattedMessage, err := m.buildHTMLMessage(msg)
+	env, err := m.prepare(msg)
 	if err != nil {
 		return err
 	}
 
-	plainMessage, err := m.buildPlainTextMessage(msg)
-	if err != nil {
-		return err
+	email := newEmail(env)
+	if email.Error != nil {
+		return email.Error
 	}
 
 	server := mail.NewSMTPClient()
@@ -191,20 +466,6 @@ func (m *Mail) SendSMTPMessage(msg Message) error {
 		return err
 	}
 
-	email := mail.NewMSG()
-	email.SetFrom(msg.From).
-		AddTo(msg.To).
-		SetSubject(msg.Subject)
-
-	email.SetBody(mail.TextHTML, formattedMessage)
-	email.AddAlternative(mail.TextPlain, plainMessage)
-
-	if len(msg.Attachments) > 0 {
-		for _, x := range msg.Attachments {
-			email.AddAttachment(x)
-		}
-	}
-
 	err = email.Send(smtpClient)
 	if err != nil {
 		return err
@@ -213,60 +474,60 @@ func (m *Mail) SendSMTPMessage(msg Message) error {
 	return nil
 }
 
-// getEncryption returns the appropriate encryption type based on a string value
-func (m *Mail) getEncryption(e string) mail.Encryption {
-	switch e {
-	case "tls":
-		return mail.EncryptionSTARTTLS
-	case "ssl":
-		return mail.EncryptionSSL
-	case "none":
-		return mail.EncryptionNone
-	default:
-		return mail.EncryptionSTARTTLS
-	}
-}
+// newEmail builds the mime message for env, as sent over smtp and written by FileTransport,
+// and signs it if the mailer has DKIM set
+func newEmail(env *Envelope) *mail.Email {
+	email := mail.NewMSG()
+	email.SetFrom(env.From.String()).
+		AddTo(addressStrings(env.To)...).
+		SetSubject(env.Subject)
 
-// buildHTMLMessage creates the html version of the message
-func (m *Mail) buildHTMLMessage(msg Message) (string, error) {
-	templateToRender := fmt.Sprintf("%s/%s.html.tmpl", m.Templates, msg.Template)
+	if len(env.CC) > 0 {
+		email.AddCc(addressStrings(env.CC)...)
+	}
 
-	t, err := template.New("email-html").ParseFiles(templateToRender)
-	if err != nil {
-		return "", err
+	if len(env.BCC) > 0 {
+		email.AddBcc(addressStrings(env.BCC)...)
 	}
 
-	var tpl bytes.Buffer
-	if err = t.ExecuteTemplate(&tpl, "body", msg.Data); err != nil {
-		return "", err
+	if env.ReplyTo != nil {
+		email.SetReplyTo(env.ReplyTo.String())
 	}
 
-	formattedMessage := tpl.String()
-	formattedMessage, err = m.inlineCSS(formattedMessage)
-	if err != nil {
-		return "", err
+	for name, value := range env.Headers {
+		email.AddHeader(name, value)
 	}
 
-	return formattedMessage, nil
-}
+	email.SetBody(mail.TextHTML, env.HTML)
+	email.AddAlternative(mail.TextPlain, env.Plain)
 
-// buildPlainTextMessage creates the plaintext version of the message
-func (m *Mail) buildPlainTextMessage(msg Message) (string, error) {
-	templateToRender := fmt.Sprintf("%s/%s.plain.tmpl", m.Templates, msg.Template)
+	for _, a := range env.Attachments {
+		email.Attach(&mail.File{Name: a.Name, Data: a.Data, MimeType: a.ContentType})
+	}
 
-	t, err := template.New("email-html").ParseFiles(templateToRender)
-	if err != nil {
-		return "", err
+	for _, a := range env.Inline {
+		email.Attach(&mail.File{Name: a.Name, Data: a.Data, MimeType: a.ContentType, Inline: true})
 	}
 
-	var tpl bytes.Buffer
-	if err = t.ExecuteTemplate(&tpl, "body", msg.Data); err != nil {
-		return "", err
+	if env.dkim != nil {
+		env.dkim.sign(email)
 	}
 
-	plainMessage := tpl.String()
+	return email
+}
 
-	return plainMessage, nil
+// getEncryption returns the appropriate encryption type based on a string value
+func (m *Mail) getEncryption(e string) mail.Encryption {
+	switch e {
+	case "tls":
+		return mail.EncryptionSTARTTLS
+	case "ssl":
+		return mail.EncryptionSSL
+	case "none":
+		return mail.EncryptionNone
+	default:
+		return mail.EncryptionSTARTTLS
+	}
 }
 
 // inlineCSS takes html input as a string, and inlines css where possible
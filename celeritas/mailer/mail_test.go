@@ -1,8 +1,16 @@
 package mailer
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 
@@ -142,4 +150,242 @@ func TestMail_ChooseAPI(t *testing.T) {
 	if err == nil {
 		t.Error(err)
 	}
-}
\ No newline at end of file
+}
+
+func TestMail_SendAsync_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			msg := Message{
+				From:     "me@here.com",
+				To:       fmt.Sprintf("you%d@there.com", i),
+				Subject:  "test",
+				Template: "test",
+			}
+
+			// every third message is refused by the server, and only its sender should see that
+			shouldFail := i%3 == 0
+			if shouldFail {
+				msg.To = fmt.Sprintf("reject%d@there.com", i)
+			}
+
+			res := <-mailer.SendAsync(context.Background(), msg)
+			if shouldFail && res.Error == nil {
+				t.Errorf("message %d: expected an error", i)
+			}
+			if !shouldFail && res.Error != nil {
+				t.Errorf("message %d: %s", i, res.Error)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestMail_SendAsync_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	msg := Message{
+		From:     "me@here.com",
+		To:       "you@there.com",
+		Subject:  "test",
+		Template: "test",
+	}
+
+	res := <-mailer.SendAsync(ctx, msg)
+	if !errors.Is(res.Error, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", res.Error)
+	}
+}
+
+func TestMail_SendInBackground(t *testing.T) {
+	msg := Message{
+		From:     "me@here.com",
+		To:       "reject-background@there.com",
+		Subject:  "test",
+		Template: "test",
+	}
+
+	before := strings.Count(mailLog.String(), msg.To)
+	for i := 0; i < 5; i++ {
+		mailer.SendInBackground(msg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := mailer.Drain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(mailLog.String(), msg.To) - before; n != 5 {
+		t.Errorf("expected 5 logged errors, got %d: %s", n, mailLog.String())
+	}
+
+	if len(mailer.Results) != 0 {
+		t.Error("background results should not be sent on Results")
+	}
+}
+
+// richMessage uses every kind of recipient, a custom header, and both kinds of attachment
+func richMessage(to string) Message {
+	return Message{
+		To:         to,
+		Recipients: []string{"Also <also@there.com>"},
+		CC:         []string{"cc@there.com"},
+		BCC:        []string{"bcc@there.com"},
+		ReplyTo:    "reply@there.com",
+		Headers:    map[string]string{"X-Custom": "custom-value"},
+		Subject:    "rich",
+		Template:   "inline",
+		Files:      []Attachment{{Name: "report.txt", Data: []byte("report contents")}},
+		Inline:     []Attachment{{Name: "logo.png", Data: []byte("\x89PNG\r\n\x1a\nnot really")}},
+	}
+}
+
+func TestMail_SendSMTPMessage_Recipients(t *testing.T) {
+	err := mailer.SendSMTPMessage(richMessage("rich@there.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := sentTo(t, "rich@there.com")
+
+	for _, rcpt := range []string{"rich@there.com", "also@there.com", "cc@there.com", "bcc@there.com"} {
+		found := false
+		for _, r := range sent.Rcpt {
+			if r == rcpt {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s was not a recipient: %v", rcpt, sent.Rcpt)
+		}
+	}
+
+	headers, _, _ := strings.Cut(sent.Data, "\r\n\r\n")
+	expected := []string{
+		`From: "Joe" <me@here.com>`,
+		"Cc: <cc@there.com>",
+		"Reply-To: <reply@there.com>",
+		"X-Custom: custom-value",
+	}
+	for _, want := range expected {
+		if !strings.Contains(headers, want) {
+			t.Errorf("expected header %q in:\n%s", want, headers)
+		}
+	}
+
+	if strings.Contains(sent.Data, "bcc@there.com") {
+		t.Error("bcc address should not appear in the message")
+	}
+
+	if !strings.Contains(sent.Data, `filename="report.txt"`) {
+		t.Error("in-memory attachment missing")
+	}
+
+	// the html refers to the inline image by the content id it was given
+	_, cid, found := strings.Cut(sent.Data, "Content-Id: <")
+	if !found {
+		t.Fatal("inline image has no content id")
+	}
+	cid, _, _ = strings.Cut(cid, ">")
+	if !strings.Contains(strings.ReplaceAll(sent.Data, "=\r\n", ""), "cid:"+cid) {
+		t.Errorf("html does not refer to cid:%s", cid)
+	}
+}
+
+func TestMail_SendSMTPMessage_FromDefaults(t *testing.T) {
+	msg := Message{
+		To:       "defaults@there.com",
+		FromName: "Jane",
+		Subject:  "test",
+		Template: "test",
+	}
+
+	err := mailer.SendSMTPMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := sentTo(t, "defaults@there.com")
+	if !strings.Contains(sent.Data, `From: "Jane" <me@here.com>`) {
+		t.Errorf("expected the mailer's address with the message's name, got:\n%s", sent.Data)
+	}
+}
+
+func TestMail_prepare_Invalid(t *testing.T) {
+	tests := map[string]Message{
+		"no recipients": {Template: "test"},
+		"bad cc":        {To: "you@there.com", CC: []string{"nope"}, Template: "test"},
+		"bad reply-to":  {To: "you@there.com", ReplyTo: "nope", Template: "test"},
+		"header newline": {
+			To:       "you@there.com",
+			Headers:  map[string]string{"X-Custom": "a\r\nBcc: someone@else.com"},
+			Template: "test",
+		},
+	}
+
+	for name, msg := range tests {
+		_, err := mailer.prepare(msg)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMail_SendUsingAPI_Transports(t *testing.T) {
+	responses := map[string]string{
+		"mailgun":   `{"message": "Queued", "id": "<1@localhost>"}`,
+		"sparkpost": `{"results": {"id": "1"}}`,
+		"sendgrid":  ``,
+	}
+
+	for transport, response := range responses {
+		var body string
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			body = string(b)
+			w.Header().Set("Content-Type", "application/json")
+			if transport == "sendgrid" {
+				w.WriteHeader(http.StatusAccepted)
+			}
+			_, _ = w.Write([]byte(response))
+		}))
+
+		m := Mail{
+			Domain:      mailer.Domain,
+			Templates:   mailer.Templates,
+			FromAddress: mailer.FromAddress,
+			FromName:    mailer.FromName,
+			API:         transport,
+			APIKey:      "abc123",
+			APIUrl:      srv.URL,
+			httpClient:  srv.Client(),
+		}
+
+		err := m.Send(richMessage("you@there.com"))
+		srv.Close()
+		if err != nil {
+			t.Errorf("%s: %s", transport, err)
+			continue
+		}
+
+		// every transport must carry the same message
+		expected := []string{
+			"you@there.com", "also@there.com", "cc@there.com", "bcc@there.com", "reply@there.com",
+			"X-Custom", "custom-value", "Joe", "me@here.com", "report.txt", "logo.png",
+		}
+		for _, want := range expected {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: %q missing from request", transport, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PreviewFixtures is the file in Templates that holds the sample data for each template
+// shown by PreviewHandler, as a json object keyed by template name
+const PreviewFixtures = "previews.json"
+
+// PreviewHandler serves pages for working on mail in development: a list of the templates
+// in Templates, each rendered as html and plain text with its data from PreviewFixtures, and
+// an inbox of the messages written by FileTransport. It shows templates and mail as they
+// are, so it must only be mounted in debug mode. The handler expects paths relative to where
+// it is mounted, so use http.StripPrefix.
+func (m *Mail) PreviewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", m.previewIndex)
+	mux.HandleFunc("GET /preview/{name}/html", m.previewHTML)
+	mux.HandleFunc("GET /preview/{name}/plain", m.previewPlain)
+	mux.HandleFunc("GET /inbox/{id}", m.inboxMessage)
+	mux.HandleFunc("GET /inbox/{id}/html", m.inboxHTML)
+	return mux
+}
+
+// previewTemplates returns the names of the templates in Templates
+func (m *Mail) previewTemplates() ([]string, error) {
+	entries, err := os.ReadDir(m.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := []string{".md.tmpl", ".html.tmpl"}
+	if m.Renderer == "jet" {
+		suffixes = append(suffixes, ".html.jet")
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, e := range entries {
+		for _, suffix := range suffixes {
+			name := strings.TrimSuffix(e.Name(), suffix)
+			if !e.IsDir() && name != e.Name() && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// previewMessage returns a message for the template name, with its data from the fixtures
+func (m *Mail) previewMessage(name string) (Message, error) {
+	msg := Message{Template: name, Subject: name}
+
+	fixtures, err := os.ReadFile(filepath.Join(m.Templates, PreviewFixtures))
+	if errors.Is(err, os.ErrNotExist) {
+		return msg, nil
+	}
+	if err != nil {
+		return msg, err
+	}
+
+	var data map[string]interface{}
+	err = json.Unmarshal(fixtures, &data)
+	if err != nil {
+		return msg, fmt.Errorf("reading %s: %w", PreviewFixtures, err)
+	}
+
+	msg.Data = data[name]
+	return msg, nil
+}
+
+// previewName returns the template name from the request, refusing anything that is not a
+// template in Templates
+func (m *Mail) previewName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.PathValue("name")
+
+	names, err := m.previewTemplates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+
+	for _, n := range names {
+		if n == name {
+			return name, true
+		}
+	}
+
+	http.NotFound(w, r)
+	return "", false
+}
+
+func (m *Mail) previewHTML(w http.ResponseWriter, r *http.Request) {
+	name, ok := m.previewName(w, r)
+	if !ok {
+		return
+	}
+
+	msg, err := m.previewMessage(name)
+	if err == nil {
+		var html string
+		html, err = m.buildHTMLMessage(msg)
+		if err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = io.WriteString(w, html)
+			return
+		}
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (m *Mail) previewPlain(w http.ResponseWriter, r *http.Request) {
+	name, ok := m.previewName(w, r)
+	if !ok {
+		return
+	}
+
+	msg, err := m.previewMessage(name)
+	if err == nil {
+		var plain string
+		plain, err = m.buildPlainTextMessage(msg)
+		if err == nil {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = io.WriteString(w, plain)
+			return
+		}
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// inboxEntry is a message written by FileTransport
+type inboxEntry struct {
+	ID          string
+	From        string
+	To          string
+	Cc          string
+	Subject     string
+	Date        time.Time
+	HTML        string
+	Plain       string
+	Attachments []string
+}
+
+// inboxDir returns the folder written by FileTransport, if that is the transport in use
+func (m *Mail) inboxDir() string {
+	if f, ok := m.Transport.(*FileTransport); ok {
+		return f.Dir
+	}
+	return ""
+}
+
+// inbox returns the messages written by FileTransport, newest first, without their bodies
+func (m *Mail) inbox() ([]inboxEntry, error) {
+	dir := m.inboxDir()
+	if dir == "" {
+		return nil, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+
+	// file names sort in the order the messages were written
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	var entries []inboxEntry
+	for _, f := range files {
+		e, err := readInboxEntry(f, false)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries, nil
+}
+
+// inboxEntryFor returns the message named in the request, refusing anything that is not
+// a message in the inbox folder
+func (m *Mail) inboxEntryFor(w http.ResponseWriter, r *http.Request) (*inboxEntry, bool) {
+	id := r.PathValue("id")
+	dir := m.inboxDir()
+	if dir == "" || id != filepath.Base(id) || !strings.HasSuffix(id, ".eml") {
+		http.NotFound(w, r)
+		return nil, false
+	}
+
+	e, err := readInboxEntry(filepath.Join(dir, id), true)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return e, true
+}
+
+func (m *Mail) inboxMessage(w http.ResponseWriter, r *http.Request) {
+	e, ok := m.inboxEntryFor(w, r)
+	if !ok {
+		return
+	}
+
+	m.previewPage(w, previewMessagePage, e)
+}
+
+func (m *Mail) inboxHTML(w http.ResponseWriter, r *http.Request) {
+	e, ok := m.inboxEntryFor(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = io.WriteString(w, e.HTML)
+}
+
+func (m *Mail) previewIndex(w http.ResponseWriter, r *http.Request) {
+	names, err := m.previewTemplates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	inbox, err := m.inbox()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	m.previewPage(w, previewIndexPage, map[string]interface{}{
+		"Templates": names,
+		"Inbox":     inbox,
+		"Capturing": m.inboxDir() != "",
+	})
+}
+
+func (m *Mail) previewPage(w http.ResponseWriter, page *htmltemplate.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := page.Execute(w, data)
+	if err != nil {
+		m.logger().Error("mailer: rendering preview page", "error", err)
+	}
+}
+
+// readInboxEntry parses the .eml file at path, and its parts when withBody is set
+func readInboxEntry(path string, withBody bool) (*inboxEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	msg, err := netmail.ReadMessage(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	dec := new(mime.WordDecoder)
+	header := func(name string) string {
+		v, err := dec.DecodeHeader(msg.Header.Get(name))
+		if err != nil {
+			return msg.Header.Get(name)
+		}
+		return v
+	}
+
+	e := &inboxEntry{
+		ID:      filepath.Base(path),
+		From:    header("From"),
+		To:      header("To"),
+		Cc:      header("Cc"),
+		Subject: header("Subject"),
+	}
+	e.Date, _ = msg.Header.Date()
+
+	if withBody {
+		err = e.readPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), "", msg.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	return e, nil
+}
+
+// readPart reads one mime part into e, descending into multipart parts
+func (e *inboxEntry) readPart(contentType, encoding, disposition string, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			// quoted-printable parts are decoded by the reader, which removes the header
+			err = e.readPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"), part)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if disposition != "" {
+		_, dparams, _ := mime.ParseMediaType(disposition)
+		e.Attachments = append(e.Attachments, dparams["filename"])
+		return nil
+	}
+
+	if strings.EqualFold(encoding, "base64") {
+		body = base64.NewDecoder(base64.StdEncoding, newlineStripper{body})
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	switch mediaType {
+	case "text/html":
+		e.HTML = string(content)
+	case "text/plain":
+		e.Plain = string(content)
+	}
+
+	return nil
+}
+
+// newlineStripper drops the line breaks from base64 encoded content
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	count, err := n.r.Read(p)
+	kept := 0
+	for _, b := range p[:count] {
+		if b != '\r' && b != '\n' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+var previewIndexPage = htmltemplate.Must(htmltemplate.New("index").Parse(`<!doctype html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>Mail</title>
+    <style>
+        body { font-family: sans-serif; margin: 2em; }
+        table { border-collapse: collapse; width: 100%; }
+        td, th { text-align: left; padding: 0.4em; border-bottom: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+<h1>Templates</h1>
+<table>
+    {{range .Templates}}
+    <tr>
+        <td>{{.}}</td>
+        <td><a href="preview/{{.}}/html">html</a></td>
+        <td><a href="preview/{{.}}/plain">plain text</a></td>
+    </tr>
+    {{else}}
+    <tr><td>No templates found.</td></tr>
+    {{end}}
+</table>
+
+<h1>Inbox</h1>
+{{if .Capturing}}
+<table>
+    <tr><th>Date</th><th>From</th><th>To</th><th>Subject</th></tr>
+    {{range .Inbox}}
+    <tr>
+        <td>{{.Date.Format "2006-01-02 15:04:05"}}</td>
+        <td>{{.From}}</td>
+        <td>{{.To}}</td>
+        <td><a href="inbox/{{.ID}}">{{.Subject}}</a></td>
+    </tr>
+    {{else}}
+    <tr><td colspan="4">No messages yet.</td></tr>
+    {{end}}
+</table>
+{{else}}
+<p>Set MAILER_API=file in .env to keep the messages the application sends, and browse them here.</p>
+{{end}}
+</body>
+</html>
+`))
+
+var previewMessagePage = htmltemplate.Must(htmltemplate.New("message").Parse(`<!doctype html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>{{.Subject}}</title>
+    <style>
+        body { font-family: sans-serif; margin: 2em; }
+        th { text-align: left; padding-right: 1em; }
+        iframe { width: 100%; height: 60vh; border: 1px solid #ddd; }
+        pre { background: #f6f6f6; padding: 1em; white-space: pre-wrap; }
+    </style>
+</head>
+<body>
+<p><a href="../">Back</a></p>
+<table>
+    <tr><th>From</th><td>{{.From}}</td></tr>
+    <tr><th>To</th><td>{{.To}}</td></tr>
+    {{if .Cc}}<tr><th>Cc</th><td>{{.Cc}}</td></tr>{{end}}
+    <tr><th>Subject</th><td>{{.Subject}}</td></tr>
+    <tr><th>Date</th><td>{{.Date.Format "2006-01-02 15:04:05"}}</td></tr>
+    {{if .Attachments}}<tr><th>Attachments</th><td>{{range .Attachments}}{{.}} {{end}}</td></tr>{{end}}
+</table>
+{{if .HTML}}<iframe src="{{.ID}}/html" sandbox></iframe>{{end}}
+{{if .Plain}}<pre>{{.Plain}}</pre>{{end}}
+</body>
+</html>
+`))
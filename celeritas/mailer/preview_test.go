@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+	body, _ := io.ReadAll(rr.Body)
+	return rr.Code, string(body)
+}
+
+func TestMail_PreviewHandler_Templates(t *testing.T) {
+	m := Mail{Templates: mailer.Templates}
+	h := m.PreviewHandler()
+
+	code, body := get(t, h, "/")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	for _, name := range []string{"digest", "inline", "test", "welcome"} {
+		if !strings.Contains(body, `href="preview/`+name+`/html"`) {
+			t.Errorf("template %s not listed", name)
+		}
+	}
+	if strings.Contains(body, "footer") || strings.Contains(body, "base") {
+		t.Error("layouts and partials should not be listed")
+	}
+
+	// the data comes from the fixture file
+	code, body = get(t, h, "/preview/welcome/html")
+	if code != http.StatusOK || !strings.Contains(body, "Welcome, Jane!") {
+		t.Errorf("html preview: %d %s", code, body)
+	}
+
+	code, body = get(t, h, "/preview/digest/plain")
+	if code != http.StatusOK || !strings.Contains(body, "# Your digest, Jane") {
+		t.Errorf("plain text preview: %d %s", code, body)
+	}
+
+	code, _ = get(t, h, "/preview/..%2fsecret/html")
+	if code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown template, got %d", code)
+	}
+}
+
+func TestMail_PreviewHandler_Inbox(t *testing.T) {
+	m := Mail{
+		Templates:   mailer.Templates,
+		FromAddress: "me@here.com",
+		Transport:   &FileTransport{Dir: t.TempDir()},
+	}
+	h := m.PreviewHandler()
+
+	_, body := get(t, h, "/")
+	if !strings.Contains(body, "No messages yet") {
+		t.Errorf("expected an empty inbox:\n%s", body)
+	}
+
+	err := m.Send(richMessage("you@there.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, body = get(t, h, "/")
+	start := strings.Index(body, `href="inbox/`)
+	if start == -1 {
+		t.Fatalf("message not listed:\n%s", body)
+	}
+	link := body[start+len(`href="`):]
+	link = "/" + link[:strings.Index(link, `"`)]
+
+	code, body := get(t, h, link)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	for _, want := range []string{"rich", "cc@there.com", "report.txt", "Enter your message content here"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in:\n%s", want, body)
+		}
+	}
+
+	code, body = get(t, h, link+"/html")
+	if code != http.StatusOK || !strings.Contains(body, "<img") {
+		t.Errorf("html part: %d %s", code, body)
+	}
+
+	code, _ = get(t, h, "/inbox/..%2f..%2fsecret.eml")
+	if code != http.StatusNotFound {
+		t.Errorf("expected 404 outside the inbox, got %d", code)
+	}
+}
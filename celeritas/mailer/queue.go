@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tschenhau/celeritas/queue"
+)
+
+// QueueJob is the name of the queue job that sends mail
+const QueueJob = "mail:send"
+
+// UseQueue registers the job that sends mail with q, so that SendQueued can hand messages
+// to queue workers. The message's Data is encoded as JSON, so templates receive it as a
+// map, and attachments must be readable by the worker.
+func (m *Mail) UseQueue(q *queue.Manager) {
+	m.queue = q
+	q.Handle(QueueJob, func(ctx context.Context, job *queue.Job) error {
+		var msg Message
+		err := job.Decode(&msg)
+		if err != nil {
+			return err
+		}
+		return m.Send(msg)
+	})
+}
+
+// SendQueued pushes msg onto the job queue, to be sent by a queue worker. Unlike sending
+// on the Jobs channel, queued mail survives a restart, and failed sends are retried.
+func (m *Mail) SendQueued(ctx context.Context, msg Message, opts ...queue.Option) error {
+	if m.queue == nil {
+		return errors.New("mailer: no queue configured; set QUEUE in .env")
+	}
+
+	_, err := m.queue.Dispatch(ctx, QueueJob, msg, opts...)
+	return err
+}
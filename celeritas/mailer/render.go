@@ -0,0 +1,342 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/yuin/goldmark"
+)
+
+// compiled holds parsed templates and jet sets, keyed by the files they were parsed from,
+// for mailers with CacheTemplates set
+var compiled sync.Map
+
+// markdownBody is replaced, for each message, by the rendered markdown. It is declared when
+// a layout is parsed, so that the layout can call {{template "body" .}} as usual.
+const markdownBody = `{{define "body"}}{{markdownBody}}{{end}}`
+
+// buildHTMLMessage creates the html version of the message. Templates are found in
+// m.Templates, and may use a layout from its layouts folder and any of the templates
+// defined in its partials folder:
+//
+//   - if the message has a name.md.tmpl file, it is executed with text/template, and the
+//     resulting markdown is converted to html, which becomes the layout's "body"
+//   - with the jet renderer, name.html.jet is executed; jet layouts use extends and import
+//   - otherwise, name.html.tmpl defines the "body" template, which the layout, if any,
+//     includes with {{template "body" .}}
+func (m *Mail) buildHTMLMessage(msg Message) (string, error) {
+	var html string
+	var err error
+
+	switch {
+	case m.isMarkdown(msg):
+		html, err = m.renderMarkdownHTML(msg)
+	case m.Renderer == "jet":
+		html, err = m.renderJet(msg.Template+".html.jet", msg.Data)
+	default:
+		html, err = m.renderHTML(msg)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return m.inlineCSS(html)
+}
+
+// buildPlainTextMessage creates the plaintext version of the message, from name.plain.tmpl or
+// name.plain.jet, or, for a markdown message, from the markdown itself
+func (m *Mail) buildPlainTextMessage(msg Message) (string, error) {
+	switch {
+	case m.isMarkdown(msg):
+		return m.renderMarkdownPlain(msg)
+	case m.Renderer == "jet":
+		return m.renderJet(msg.Template+".plain.jet", msg.Data)
+	default:
+		return m.renderPlain(msg)
+	}
+}
+
+func (m *Mail) isMarkdown(msg Message) bool {
+	_, err := os.Stat(m.templatePath(msg.Template + ".md.tmpl"))
+	return err == nil
+}
+
+func (m *Mail) templatePath(name string) string {
+	return filepath.Join(m.Templates, name)
+}
+
+// layout returns the layout for msg, if there is one
+func (m *Mail) layout(msg Message) string {
+	if msg.Layout != "" {
+		return msg.Layout
+	}
+	return m.Layout
+}
+
+// layoutFiles returns the partials with the given extension, such as html.tmpl, followed by
+// msg's layout, and the name of the template to execute: the layout, or "body" without one.
+// A plain text layout may be left out, in which case the plain text is not wrapped.
+func (m *Mail) layoutFiles(msg Message, ext string) ([]string, string, error) {
+	files, err := filepath.Glob(m.templatePath(filepath.Join("partials", "*."+ext)))
+	if err != nil {
+		return nil, "", err
+	}
+
+	layout := m.layout(msg)
+	if layout == "" {
+		return files, "body", nil
+	}
+
+	path := m.templatePath(filepath.Join("layouts", layout+"."+ext))
+	if _, err := os.Stat(path); err != nil && ext == "plain.tmpl" {
+		return files, "body", nil
+	}
+
+	return append(files, path), filepath.Base(path), nil
+}
+
+// renderHTML executes msg's html/template template
+func (m *Mail) renderHTML(msg Message) (string, error) {
+	files, name, err := m.layoutFiles(msg, "html.tmpl")
+	if err != nil {
+		return "", err
+	}
+	files = append(files, m.templatePath(msg.Template+".html.tmpl"))
+
+	t, err := m.parseHTML(files, "")
+	if err != nil {
+		return "", err
+	}
+
+	var tpl bytes.Buffer
+	if err = t.ExecuteTemplate(&tpl, name, msg.Data); err != nil {
+		return "", err
+	}
+
+	return tpl.String(), nil
+}
+
+// renderPlain executes msg's text/template template
+func (m *Mail) renderPlain(msg Message) (string, error) {
+	files, name, err := m.layoutFiles(msg, "plain.tmpl")
+	if err != nil {
+		return "", err
+	}
+	files = append(files, m.templatePath(msg.Template+".plain.tmpl"))
+
+	t, err := m.parseText(files, "")
+	if err != nil {
+		return "", err
+	}
+
+	var tpl bytes.Buffer
+	if err = t.ExecuteTemplate(&tpl, name, msg.Data); err != nil {
+		return "", err
+	}
+
+	return tpl.String(), nil
+}
+
+// renderMarkdownPlain executes msg's markdown template, and wraps the result in the plain
+// text layout, if there is one; markdown reads well enough as it is
+func (m *Mail) renderMarkdownPlain(msg Message) (string, error) {
+	source, err := m.renderMarkdownSource(msg)
+	if err != nil {
+		return "", err
+	}
+
+	files, name, err := m.layoutFiles(msg, "plain.tmpl")
+	if err != nil {
+		return "", err
+	}
+	if name == "body" {
+		return source, nil
+	}
+
+	t, err := m.parseText(files, markdownBody)
+	if err != nil {
+		return "", err
+	}
+
+	// the parsed template may be cached and shared, so each message gets a copy
+	t, err = t.Clone()
+	if err != nil {
+		return "", err
+	}
+	t.Funcs(texttemplate.FuncMap{"markdownBody": func() string { return source }})
+
+	var tpl bytes.Buffer
+	if err = t.ExecuteTemplate(&tpl, name, msg.Data); err != nil {
+		return "", err
+	}
+
+	return tpl.String(), nil
+}
+
+// renderMarkdownHTML converts msg's markdown to html, and wraps it in the layout, if there is one
+func (m *Mail) renderMarkdownHTML(msg Message) (string, error) {
+	source, err := m.renderMarkdownSource(msg)
+	if err != nil {
+		return "", err
+	}
+
+	var converted bytes.Buffer
+	err = goldmark.Convert([]byte(source), &converted)
+	if err != nil {
+		return "", err
+	}
+
+	files, name, err := m.layoutFiles(msg, "html.tmpl")
+	if err != nil {
+		return "", err
+	}
+	if name == "body" {
+		return converted.String(), nil
+	}
+
+	t, err := m.parseHTML(files, markdownBody)
+	if err != nil {
+		return "", err
+	}
+
+	// a cached template has never been executed, so it can be cloned for each message
+	t, err = t.Clone()
+	if err != nil {
+		return "", err
+	}
+	t.Funcs(htmltemplate.FuncMap{
+		"markdownBody": func() htmltemplate.HTML { return htmltemplate.HTML(converted.String()) },
+	})
+
+	var tpl bytes.Buffer
+	if err = t.ExecuteTemplate(&tpl, name, msg.Data); err != nil {
+		return "", err
+	}
+
+	return tpl.String(), nil
+}
+
+// renderMarkdownSource executes msg's markdown template with text/template. Goldmark leaves
+// out raw html, so data cannot add markup of its own to the html version.
+func (m *Mail) renderMarkdownSource(msg Message) (string, error) {
+	path := m.templatePath(msg.Template + ".md.tmpl")
+
+	t, err := m.parseText([]string{path}, "")
+	if err != nil {
+		return "", err
+	}
+
+	var tpl bytes.Buffer
+	if err = t.ExecuteTemplate(&tpl, filepath.Base(path), msg.Data); err != nil {
+		return "", err
+	}
+
+	return tpl.String(), nil
+}
+
+// templateFuncs are declared for every template, so that templates parsed for markdown
+// can be cached; each message supplies its own markdownBody
+var templateFuncs = map[string]interface{}{
+	"markdownBody": func() string { return "" },
+}
+
+// parseHTML parses files, and then extra, if set, with html/template
+func (m *Mail) parseHTML(files []string, extra string) (*htmltemplate.Template, error) {
+	key := "html:" + strings.Join(files, ",") + extra
+	if m.CacheTemplates {
+		if t, ok := compiled.Load(key); ok {
+			return t.(*htmltemplate.Template), nil
+		}
+	}
+
+	t, err := htmltemplate.New("email-html").Funcs(templateFuncs).ParseFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	if extra != "" {
+		t, err = t.Parse(extra)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CacheTemplates {
+		compiled.Store(key, t)
+	}
+
+	return t, nil
+}
+
+// parseText parses files, and then extra, if set, with text/template
+func (m *Mail) parseText(files []string, extra string) (*texttemplate.Template, error) {
+	key := "text:" + strings.Join(files, ",") + extra
+	if m.CacheTemplates {
+		if t, ok := compiled.Load(key); ok {
+			return t.(*texttemplate.Template), nil
+		}
+	}
+
+	t, err := texttemplate.New("email-plain").Funcs(templateFuncs).ParseFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	if extra != "" {
+		t, err = t.Parse(extra)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m.CacheTemplates {
+		compiled.Store(key, t)
+	}
+
+	return t, nil
+}
+
+// renderJet executes the jet template name with data as its context
+func (m *Mail) renderJet(name string, data interface{}) (string, error) {
+	t, err := m.jetViews().GetTemplate(name)
+	if err != nil {
+		return "", err
+	}
+
+	var tpl bytes.Buffer
+	if err = t.Execute(&tpl, nil, data); err != nil {
+		return "", err
+	}
+
+	return tpl.String(), nil
+}
+
+// jetViews returns JetViews, or a set that loads from Templates in the same way as the
+// views set: cached, unless CacheTemplates is false
+func (m *Mail) jetViews() *jet.Set {
+	if m.JetViews != nil {
+		return m.JetViews
+	}
+
+	key := fmt.Sprintf("jet:%s:%t", m.Templates, m.CacheTemplates)
+	if views, ok := compiled.Load(key); ok {
+		return views.(*jet.Set)
+	}
+
+	var views *jet.Set
+	if m.CacheTemplates {
+		views = jet.NewSet(jet.NewOSFileSystemLoader(m.Templates))
+	} else {
+		views = jet.NewSet(jet.NewOSFileSystemLoader(m.Templates), jet.InDevelopmentMode())
+	}
+
+	actual, _ := compiled.LoadOrStore(key, views)
+	return actual.(*jet.Set)
+}
@@ -0,0 +1,136 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type welcomeData struct {
+	Name  string
+	App   string
+	Items []string
+}
+
+func TestMail_buildHTMLMessage_Layout(t *testing.T) {
+	m := Mail{Templates: mailer.Templates, Layout: "base"}
+	msg := Message{Template: "welcome", Data: welcomeData{Name: "<Jane>", App: "myapp"}}
+
+	html, err := m.buildHTMLMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`<div class="content">`, "Welcome, &lt;Jane&gt;!", `<p class="footer">Sent by myapp</p>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in:\n%s", want, html)
+		}
+	}
+
+	plain, err := m.buildPlainTextMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plain text is not html escaped
+	if !strings.Contains(plain, "Welcome, <Jane>!") || !strings.Contains(plain, "Sent by myapp") {
+		t.Errorf("plain text layout not applied: %q", plain)
+	}
+
+	// a message can name its own layout, which must exist
+	msg.Layout = "missing"
+	_, err = m.buildHTMLMessage(msg)
+	if err == nil {
+		t.Error("expected an error for a missing layout")
+	}
+}
+
+func TestMail_buildHTMLMessage_Jet(t *testing.T) {
+	m := Mail{Templates: mailer.Templates, Renderer: "jet"}
+	msg := Message{Template: "welcome", Data: welcomeData{Name: "Jane"}}
+
+	html, err := m.buildHTMLMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(html, `<div class="content"><p>Welcome, Jane!</p></div>`) {
+		t.Errorf("jet layout not applied:\n%s", html)
+	}
+
+	plain, err := m.buildPlainTextMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(plain) != "Welcome, Jane!" {
+		t.Errorf("wrong plain text: %q", plain)
+	}
+}
+
+func TestMail_buildHTMLMessage_Markdown(t *testing.T) {
+	data := welcomeData{Name: "Jane", App: "myapp", Items: []string{"one", "<script>two</script>"}}
+
+	for _, layout := range []string{"", "base"} {
+		m := Mail{Templates: mailer.Templates, Layout: layout}
+		msg := Message{Template: "digest", Data: data}
+
+		html, err := m.buildHTMLMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{"<h1>Your digest, Jane</h1>", "<strong>2</strong>", "<li>one</li>"} {
+			if !strings.Contains(html, want) {
+				t.Errorf("layout %q: expected %q in:\n%s", layout, want, html)
+			}
+		}
+
+		if strings.Contains(html, "<script>") {
+			t.Errorf("layout %q: raw html in markdown data should be left out:\n%s", layout, html)
+		}
+
+		if layout != "" && !strings.Contains(html, `<p class="footer">Sent by myapp</p>`) {
+			t.Errorf("layout not applied to markdown:\n%s", html)
+		}
+
+		plain, err := m.buildPlainTextMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(plain, "# Your digest, Jane") || !strings.Contains(plain, "- one") {
+			t.Errorf("layout %q: plain text should be the markdown: %q", layout, plain)
+		}
+	}
+}
+
+func TestMail_CacheTemplates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(body string) {
+		err := os.WriteFile(filepath.Join(dir, "cached.plain.tmpl"), []byte(`{{define "body"}}`+body+`{{end}}`), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg := Message{Template: "cached"}
+	cached := Mail{Templates: dir, CacheTemplates: true}
+	uncached := Mail{Templates: dir}
+
+	write("first")
+	for _, m := range []Mail{cached, uncached} {
+		if plain, _ := m.buildPlainTextMessage(msg); plain != "first" {
+			t.Fatalf("expected first, got %q", plain)
+		}
+	}
+
+	write("second")
+	if plain, _ := cached.buildPlainTextMessage(msg); plain != "first" {
+		t.Errorf("cached template was parsed again: %q", plain)
+	}
+	if plain, _ := uncached.buildPlainTextMessage(msg); plain != "second" {
+		t.Errorf("template should be parsed for every message when not caching: %q", plain)
+	}
+}
@@ -1,8 +1,18 @@
 package mailer
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,12 +34,39 @@ var mailer = Mail{
 	FromName: "Joe",
 	Jobs: make(chan Message, 1),
 	Results: make(chan Result, 1),
+	Workers: 4,
+	Logger: slog.New(slog.NewTextHandler(&mailLog, nil)),
+}
+
+// mailLog holds what the mailer logs
+var mailLog syncBuffer
+
+// syncBuffer is a bytes.Buffer that is safe to write from the mail workers while a test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
 
 func TestMain(m *testing.M) {
 	p, err := dockertest.NewPool("")
+	if err == nil {
+		err = p.Client.Ping()
+	}
 	if err != nil {
-		log.Fatal("could not connect to docker", err)
+		log.Println("docker is not available, so using an in-process smtp server:", err)
+		os.Exit(runWithSMTPSink(m))
 	}
 	pool = p
 
@@ -66,4 +103,147 @@ func TestMain(m *testing.M) {
 	}
 
 	os.Exit(code)
-}
\ No newline at end of file
+}
+
+// sentMessage is a message received by the smtp server
+type sentMessage struct {
+	Rcpt []string
+	Data string
+}
+
+// sink holds the messages received by the in-process smtp server; it is nil when using mailhog
+var sink *smtpSink
+
+type smtpSink struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (s *smtpSink) add(msg sentMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+}
+
+// sentTo returns the last message delivered to address, from the in-process server or from mailhog
+func sentTo(t *testing.T, address string) sentMessage {
+	t.Helper()
+
+	if sink != nil {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		for i := len(sink.sent) - 1; i >= 0; i-- {
+			for _, rcpt := range sink.sent[i].Rcpt {
+				if strings.EqualFold(rcpt, address) {
+					return sink.sent[i]
+				}
+			}
+		}
+		t.Fatalf("no message sent to %s", address)
+	}
+
+	res, err := http.Get("http://localhost:8026/api/v2/search?kind=to&query=" + url.QueryEscape(address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var found struct {
+		Items []struct {
+			Raw struct {
+				To   []string
+				Data string
+			}
+		}
+	}
+	err = json.NewDecoder(res.Body).Decode(&found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Items) == 0 {
+		t.Fatalf("no message sent to %s", address)
+	}
+
+	return sentMessage{Rcpt: found.Items[0].Raw.To, Data: found.Items[0].Raw.Data}
+}
+
+// runWithSMTPSink runs the tests against a minimal smtp server that accepts every message,
+// and refuses any recipient containing "reject"
+func runWithSMTPSink(m *testing.M) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn)
+		}
+	}()
+
+	sink = &smtpSink{}
+	mailer.Port = l.Addr().(*net.TCPAddr).Port
+	go mailer.ListenForMail()
+
+	return m.Run()
+}
+
+func serveSMTP(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) {
+		_, _ = fmt.Fprintf(conn, "%s\r\n", s)
+	}
+
+	var msg sentMessage
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "RCPT TO") && strings.Contains(cmd, "REJECT"):
+			reply("550 no such user")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			rcpt := strings.TrimSpace(line[len("RCPT TO:"):])
+			msg.Rcpt = append(msg.Rcpt, strings.Trim(rcpt, "<>"))
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err = r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				// undo the client's dot stuffing
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg.Data = data.String()
+			sink.add(msg)
+			msg = sentMessage{}
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
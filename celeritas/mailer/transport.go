@@ -0,0 +1,205 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport delivers messages that Mail has rendered and checked. Setting Mail.Transport
+// replaces smtp and the apis, which is useful in development and in tests.
+type Transport interface {
+	Deliver(env *Envelope) error
+}
+
+// LogTransport writes each message to Logger instead of sending it. It is chosen by
+// setting MAILER_API to log.
+type LogTransport struct {
+	Logger *slog.Logger
+}
+
+// Deliver logs env
+func (l *LogTransport) Deliver(env *Envelope) error {
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var attachments []string
+	for _, a := range env.Attachments {
+		attachments = append(attachments, a.Name)
+	}
+
+	logger.Info("mail",
+		"from", env.From.String(),
+		"to", addressStrings(env.To),
+		"cc", addressStrings(env.CC),
+		"bcc", addressStrings(env.BCC),
+		"subject", env.Subject,
+		"attachments", attachments,
+		"body", env.Plain,
+	)
+
+	return nil
+}
+
+// FileTransport writes each message to an .eml file in Dir instead of sending it, exactly
+// as it would have gone over smtp, so that it can be opened in a mail client. It is chosen
+// by setting MAILER_API to file, which writes to tmp/mail.
+type FileTransport struct {
+	Dir string
+}
+
+// Deliver writes env to a new file in Dir
+func (f *FileTransport) Deliver(env *Envelope) error {
+	email := newEmail(env)
+	if email.Error != nil {
+		return email.Error
+	}
+
+	err := os.MkdirAll(f.Dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 4)
+	_, err = rand.Read(b)
+	if err != nil {
+		return err
+	}
+
+	// names sort in the order the messages were sent
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), hex.EncodeToString(b))
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(emailMessage(email)), 0644)
+}
+
+// TestingT is the part of testing.TB used by FakeMailer's assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// FakeMailer records messages instead of sending them, so that code which sends mail can
+// be tested without a mail server:
+//
+//	fake := app.Mail.Fake()
+//	// ... call the handler
+//	env := fake.AssertSentTo(t, "you@there.com")
+type FakeMailer struct {
+	// Err, when set, is returned for every message, to test how failures are handled
+	Err error
+
+	mu   sync.Mutex
+	sent []*Envelope
+}
+
+// Fake sets m's transport to a new FakeMailer, and returns it
+func (m *Mail) Fake() *FakeMailer {
+	f := &FakeMailer{}
+	m.Transport = f
+	return f
+}
+
+// Deliver records env, unless Err is set
+func (f *FakeMailer) Deliver(env *Envelope) error {
+	if f.Err != nil {
+		return f.Err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, env)
+	return nil
+}
+
+// Sent returns the messages recorded so far, oldest first
+func (f *FakeMailer) Sent() []*Envelope {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*Envelope(nil), f.sent...)
+}
+
+// SentTo returns the messages with address among their to, cc or bcc recipients
+func (f *FakeMailer) SentTo(address string) []*Envelope {
+	var found []*Envelope
+	for _, env := range f.Sent() {
+		if env.sentTo(address) {
+			found = append(found, env)
+		}
+	}
+	return found
+}
+
+// Reset forgets the recorded messages
+func (f *FakeMailer) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = nil
+}
+
+// AssertSentTo fails the test unless a message was sent to address, and returns the last one
+func (f *FakeMailer) AssertSentTo(t TestingT, address string) *Envelope {
+	t.Helper()
+
+	found := f.SentTo(address)
+	if len(found) == 0 {
+		t.Errorf("expected a message to %s, but none was sent", address)
+		return nil
+	}
+	return found[len(found)-1]
+}
+
+// AssertNotSentTo fails the test if a message was sent to address
+func (f *FakeMailer) AssertNotSentTo(t TestingT, address string) {
+	t.Helper()
+
+	if n := len(f.SentTo(address)); n > 0 {
+		t.Errorf("expected no message to %s, but %d were sent", address, n)
+	}
+}
+
+// AssertCount fails the test unless exactly n messages were sent
+func (f *FakeMailer) AssertCount(t TestingT, n int) {
+	t.Helper()
+
+	if sent := len(f.Sent()); sent != n {
+		t.Errorf("expected %d messages to be sent, got %d", n, sent)
+	}
+}
+
+// AssertNothingSent fails the test if any message was sent
+func (f *FakeMailer) AssertNothingSent(t TestingT) {
+	t.Helper()
+	f.AssertCount(t, 0)
+}
+
+// AssertSubject fails the test unless a message was sent with the given subject
+func (f *FakeMailer) AssertSubject(t TestingT, subject string) {
+	t.Helper()
+
+	for _, env := range f.Sent() {
+		if env.Subject == subject {
+			return
+		}
+	}
+	t.Errorf("expected a message with subject %q, but none was sent", subject)
+}
+
+// sentTo reports whether address is among env's recipients
+func (env *Envelope) sentTo(address string) bool {
+	for _, list := range [][]*netmail.Address{env.To, env.CC, env.BCC} {
+		for _, a := range list {
+			if strings.EqualFold(a.Address, address) {
+				return true
+			}
+		}
+	}
+	return false
+}
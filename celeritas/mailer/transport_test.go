@@ -0,0 +1,130 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogTransport(t *testing.T) {
+	var buf bytes.Buffer
+	m := Mail{
+		Templates:   mailer.Templates,
+		FromAddress: "me@here.com",
+		Transport:   &LogTransport{Logger: slog.New(slog.NewTextHandler(&buf, nil))},
+	}
+
+	err := m.Send(Message{To: "you@there.com", Subject: "logged", Template: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"you@there.com", "subject=logged", "Enter your message content here"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in the log, got %s", want, buf.String())
+		}
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := Mail{
+		Templates:   mailer.Templates,
+		FromAddress: "me@here.com",
+		FromName:    "Joe",
+		Transport:   &FileTransport{Dir: dir},
+	}
+
+	err := m.Send(richMessage("you@there.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v %v", files, err)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	msg, err := netmail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Header.Get("Subject") != "rich" || msg.Header.Get("X-Custom") != "custom-value" {
+		t.Errorf("wrong headers: %v", msg.Header)
+	}
+
+	from, err := msg.Header.AddressList("From")
+	if err != nil || from[0].Name != "Joe" || from[0].Address != "me@here.com" {
+		t.Errorf("wrong sender: %v %v", from, err)
+	}
+}
+
+func TestFakeMailer(t *testing.T) {
+	m := Mail{
+		Templates:   mailer.Templates,
+		FromAddress: "me@here.com",
+		Jobs:        make(chan Message, 1),
+	}
+	fake := m.Fake()
+
+	go m.ListenForMail()
+	defer close(m.Jobs)
+
+	fake.AssertNothingSent(t)
+
+	res := <-m.SendAsync(context.Background(), richMessage("you@there.com"))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+
+	fake.AssertCount(t, 1)
+	fake.AssertSubject(t, "rich")
+	fake.AssertNotSentTo(t, "nobody@there.com")
+
+	// cc and bcc recipients count as recipients too
+	fake.AssertSentTo(t, "bcc@there.com")
+	env := fake.AssertSentTo(t, "you@there.com")
+	if env == nil || !strings.Contains(env.HTML, "cid:logo.png") {
+		t.Errorf("expected the rendered message, got %+v", env)
+	}
+
+	// the assertions report failures to the test
+	var rec recorder
+	fake.AssertSentTo(&rec, "nobody@there.com")
+	fake.AssertNothingSent(&rec)
+	if rec.failures != 2 {
+		t.Errorf("expected 2 failed assertions, got %d", rec.failures)
+	}
+
+	fake.Reset()
+	fake.Err = errors.New("mail server on fire")
+	res = <-m.SendAsync(context.Background(), Message{To: "you@there.com", Template: "test"})
+	if res.Error != fake.Err {
+		t.Errorf("expected the fake's error, got %v", res.Error)
+	}
+	fake.AssertNothingSent(t)
+}
+
+// recorder counts failed assertions
+type recorder struct {
+	failures int
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures++
+}
@@ -2,27 +2,36 @@ package celeritas
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/justinas/nosurf"
+	"github.com/tschenhau/celeritas/session"
 )
 
+// SessionLoad loads the session of each request, and saves it once the request is handled,
+// recording the sessions of logged in users in UserSessions. Cookie sessions are saved by
+// their store, which writes the session into the cookie.
 func (c *Celeritas) SessionLoad(next http.Handler) http.Handler {
+	if c.UserSessions != nil {
+		next = c.UserSessions.Track(next)
+	}
+
+	if store, ok := c.Session.Store.(*session.CookieStore); ok {
+		return store.LoadAndSave(c.Session, next)
+	}
 	return c.Session.LoadAndSave(next)
 }
 
 func (c *Celeritas) NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
-	secure, _ := strconv.ParseBool(c.config.cookie.secure)
 
 	csrfHandler.ExemptGlob("/api/*")
 
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
-		Secure:   secure,
+		Secure:   c.Config.Cookie.Secure,
 		SameSite: http.SameSiteStrictMode,
-		Domain:   c.config.cookie.domain,
+		Domain:   c.Config.Cookie.Domain,
 	})
 
 	return csrfHandler
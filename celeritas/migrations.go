@@ -2,17 +2,19 @@ package celeritas
 
 import (
 	"log"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/golang-migrate/migrate/v4/database/mysql"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 func (c *Celeritas) MigrateUp(dsn string) error {
-	m, err := migrate.New("file://" + c.RootPath + "/migrations", dsn)
+	m, err := c.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
@@ -26,7 +28,7 @@ func (c *Celeritas) MigrateUp(dsn string) error {
 }
 
 func (c *Celeritas) MigrateDownAll(dsn string) error {
-	m, err := migrate.New("file://" + c.RootPath + "/migrations", dsn)
+	m, err := c.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
@@ -40,7 +42,7 @@ func (c *Celeritas) MigrateDownAll(dsn string) error {
 }
 
 func (c *Celeritas) Steps(n int, dsn string) error {
-	m, err := migrate.New("file://" + c.RootPath + "/migrations", dsn)
+	m, err := c.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
@@ -54,7 +56,7 @@ func (c *Celeritas) Steps(n int, dsn string) error {
 }
 
 func (c *Celeritas) MigrateForce(dsn string) error {
-	m, err := migrate.New("file://" + c.RootPath + "/migrations", dsn)
+	m, err := c.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
@@ -65,4 +67,17 @@ func (c *Celeritas) MigrateForce(dsn string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// newMigrate creates a migrate instance for the migrations folder. For sqlite, it also makes sure
+// that the folder holding the database file exists.
+func (c *Celeritas) newMigrate(dsn string) (*migrate.Migrate, error) {
+	if strings.HasPrefix(dsn, "sqlite3://") {
+		err := ensureSQLiteDir(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return migrate.New("file://"+c.RootPath+"/migrations", dsn)
+}
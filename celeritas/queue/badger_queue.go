@@ -0,0 +1,253 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// BadgerQueue keeps jobs in a badger database. Waiting jobs are stored under keys that
+// sort by the time at which they become available, reserved jobs under a key of their
+// own along with the time their reservation runs out, and failed jobs under "failed:".
+// The database should not be shared with a cache, since emptying the cache would
+// remove the jobs.
+type BadgerQueue struct {
+	Conn *badger.DB
+
+	// mu serializes Pop; badger can only be opened by one process, so this is enough to
+	// stop workers from conflicting over the same job
+	mu sync.Mutex
+}
+
+// reservation is the value stored for a reserved job
+type reservation struct {
+	Job   Job       `json:"job"`
+	Until time.Time `json:"until"`
+}
+
+// queueKey returns the part of a key naming queue. The name is preceded by its length, so
+// that a queue called emails does not take in the keys of one called emails:high.
+func queueKey(queue string) string {
+	return fmt.Sprintf("%d:%s", len(queue), queue)
+}
+
+func waitingPrefix(queue string) []byte {
+	return []byte(fmt.Sprintf("waiting:%s:", queueKey(queue)))
+}
+
+func waitingKey(job *Job) []byte {
+	return []byte(fmt.Sprintf("waiting:%s:%020d:%s", queueKey(job.Queue), job.AvailableAt.UnixNano(), job.ID))
+}
+
+func reservedPrefix(queue string) []byte {
+	return []byte(fmt.Sprintf("reserved:%s:", queueKey(queue)))
+}
+
+func reservedKey(job *Job) []byte {
+	return []byte(fmt.Sprintf("reserved:%s:%s", queueKey(job.Queue), job.ID))
+}
+
+func failedKey(job *Job) []byte {
+	return []byte(fmt.Sprintf("failed:%020d:%s", time.Now().UnixNano(), job.ID))
+}
+
+// update runs fn in a read-write transaction, retrying when it conflicts with another worker
+func (q *BadgerQueue) update(fn func(txn *badger.Txn) error) error {
+	var err error
+	for i := 0; i < 10; i++ {
+		err = q.Conn.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+func setJSON(txn *badger.Txn, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return txn.Set(key, data)
+}
+
+// Push adds job to its queue
+func (q *BadgerQueue) Push(ctx context.Context, job *Job) error {
+	return q.update(func(txn *badger.Txn) error {
+		return setJSON(txn, waitingKey(job), job)
+	})
+}
+
+// Pop reserves the next available job on queue
+func (q *BadgerQueue) Pop(ctx context.Context, queue string, reserveFor time.Duration) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var popped *Job
+
+	err := q.update(func(txn *badger.Txn) error {
+		popped = nil
+		now := time.Now()
+
+		// put jobs whose reservation has run out back on the queue
+		var expired []reservation
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		prefix := reservedPrefix(queue)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var r reservation
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &r)
+			})
+			if err != nil {
+				it.Close()
+				return err
+			}
+			if !r.Until.After(now) {
+				expired = append(expired, r)
+			}
+		}
+		it.Close()
+
+		for _, r := range expired {
+			job := r.Job
+			err := txn.Delete(reservedKey(&job))
+			if err != nil {
+				return err
+			}
+			job.AvailableAt = now
+			err = setJSON(txn, waitingKey(&job), &job)
+			if err != nil {
+				return err
+			}
+		}
+
+		// the first waiting key is the job that has been due the longest
+		var key []byte
+		var job Job
+		it = txn.NewIterator(badger.DefaultIteratorOptions)
+		prefix = waitingPrefix(queue)
+		it.Seek(prefix)
+		if it.ValidForPrefix(prefix) {
+			key = it.Item().KeyCopy(nil)
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &job)
+			})
+			if err != nil {
+				it.Close()
+				return err
+			}
+		}
+		it.Close()
+
+		if key == nil || job.AvailableAt.After(now) {
+			return nil
+		}
+
+		err := txn.Delete(key)
+		if err != nil {
+			return err
+		}
+
+		job.Attempts++
+		err = setJSON(txn, reservedKey(&job), reservation{Job: job, Until: now.Add(reserveFor)})
+		if err != nil {
+			return err
+		}
+
+		popped = &job
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return popped, nil
+}
+
+// Ack removes a completed job
+func (q *BadgerQueue) Ack(ctx context.Context, job *Job) error {
+	return q.update(func(txn *badger.Txn) error {
+		return txn.Delete(reservedKey(job))
+	})
+}
+
+// Release returns a reserved job to its queue, to be tried again after delay
+func (q *BadgerQueue) Release(ctx context.Context, job *Job, delay time.Duration) error {
+	released := *job
+	released.AvailableAt = time.Now().Add(delay)
+
+	return q.update(func(txn *badger.Txn) error {
+		err := txn.Delete(reservedKey(job))
+		if err != nil {
+			return err
+		}
+		return setJSON(txn, waitingKey(&released), &released)
+	})
+}
+
+// Fail records a reserved job as failed and removes it from its queue
+func (q *BadgerQueue) Fail(ctx context.Context, job *Job, reason error) error {
+	failed := FailedJob{Job: *job, Error: reason.Error(), FailedAt: time.Now()}
+
+	return q.update(func(txn *badger.Txn) error {
+		err := txn.Delete(reservedKey(job))
+		if err != nil {
+			return err
+		}
+		return setJSON(txn, failedKey(job), failed)
+	})
+}
+
+// Failed returns the failed jobs, most recent first
+func (q *BadgerQueue) Failed(ctx context.Context) ([]FailedJob, error) {
+	var failed []FailedJob
+
+	err := q.Conn.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// in reverse, seek to just past the last key with the prefix
+		prefix := []byte("failed:")
+		for it.Seek([]byte("failed;")); it.ValidForPrefix(prefix); it.Next() {
+			var f FailedJob
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &f)
+			})
+			if err != nil {
+				return err
+			}
+			failed = append(failed, f)
+		}
+		return nil
+	})
+
+	return failed, err
+}
+
+// Size returns the number of waiting and reserved jobs on queue
+func (q *BadgerQueue) Size(ctx context.Context, queue string) (int, error) {
+	n := 0
+
+	err := q.Conn.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for _, prefix := range [][]byte{waitingPrefix(queue), reservedPrefix(queue)} {
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				n++
+			}
+		}
+		return nil
+	})
+
+	return n, err
+}
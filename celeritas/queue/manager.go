@@ -0,0 +1,259 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Handler runs a job. Returning an error causes the job to be retried, until it has
+// used up its attempts and is moved to the failed jobs.
+type Handler func(ctx context.Context, job *Job) error
+
+// Manager dispatches jobs to a Queue driver, and runs workers that pass them to the
+// handler registered for their name. Zero values are replaced with sensible defaults.
+type Manager struct {
+	Queue Queue
+	// DefaultQueue is the queue jobs are dispatched to, and workers read from, when no
+	// queue is named. It defaults to "default".
+	DefaultQueue string
+	// Concurrency is the number of jobs run at the same time by Work. It defaults to 1.
+	Concurrency int
+	// MaxAttempts is how many times a job is tried unless it was dispatched with the
+	// MaxAttempts option. It defaults to 3.
+	MaxAttempts int
+	// RetryAfter is how long a worker may hold a job. A handler's context is cancelled
+	// after this long, and a job held by a worker that died is run again after it.
+	// It defaults to 90 seconds.
+	RetryAfter time.Duration
+	// PollInterval is how long an idle worker waits before checking the queues again.
+	// It defaults to one second.
+	PollInterval time.Duration
+	// Backoff returns how long to wait before retrying a job that has failed attempts
+	// times. It defaults to ExponentialBackoff(10*time.Second, time.Hour).
+	Backoff func(attempts int) time.Duration
+	Logger  *slog.Logger
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// Handle registers the handler for jobs called name
+func (m *Manager) Handle(name string, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.handlers == nil {
+		m.handlers = make(map[string]Handler)
+	}
+	m.handlers[name] = h
+}
+
+// Dispatch pushes a job called name onto the queue, with payload encoded as JSON,
+// and returns it
+func (m *Manager) Dispatch(ctx context.Context, name string, payload interface{}, opts ...Option) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          id,
+		Queue:       m.defaultQueue(),
+		Name:        name,
+		Payload:     data,
+		MaxAttempts: m.maxAttempts(),
+		AvailableAt: now,
+		CreatedAt:   now,
+	}
+
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	err = m.Queue.Push(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Work runs Concurrency workers that take jobs from queues, in the order given, or from
+// the default queue if none are given. It blocks until ctx is cancelled, and then waits
+// for the jobs that are running to finish.
+func (m *Manager) Work(ctx context.Context, queues ...string) error {
+	if m.Queue == nil {
+		return errors.New("queue: no driver set")
+	}
+
+	if len(queues) == 0 {
+		queues = []string{m.defaultQueue()}
+	}
+
+	concurrency := m.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.worker(ctx, queues)
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// worker runs jobs until ctx is cancelled
+func (m *Manager) worker(ctx context.Context, queues []string) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := m.next(ctx, queues)
+		if err != nil && ctx.Err() == nil {
+			m.logger().Error("queue: reserving job", "error", err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(m.pollInterval()):
+			}
+			continue
+		}
+
+		m.process(ctx, job)
+	}
+}
+
+// next reserves the first available job from queues
+func (m *Manager) next(ctx context.Context, queues []string) (*Job, error) {
+	for _, q := range queues {
+		job, err := m.Queue.Pop(ctx, q, m.retryAfter())
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// process runs a reserved job, and then acknowledges, releases or fails it. The handler
+// is not cancelled when ctx is, so that a worker which is stopping finishes its job.
+func (m *Manager) process(ctx context.Context, job *Job) {
+	log := m.logger().With("job_id", job.ID, "job", job.Name, "queue", job.Queue, "attempt", job.Attempts)
+	ctx = context.WithoutCancel(ctx)
+
+	m.mu.RLock()
+	h, ok := m.handlers[job.Name]
+	m.mu.RUnlock()
+
+	if !ok {
+		err := fmt.Errorf("no handler registered for job %s", job.Name)
+		log.Error("queue: job failed", "error", err)
+		if err := m.Queue.Fail(ctx, job, err); err != nil {
+			log.Error("queue: recording failed job", "error", err)
+		}
+		return
+	}
+
+	start := time.Now()
+	err := m.run(ctx, h, job)
+	if err == nil {
+		log.Debug("queue: job done", "latency", time.Since(start))
+		if err := m.Queue.Ack(ctx, job); err != nil {
+			log.Error("queue: acknowledging job", "error", err)
+		}
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		log.Error("queue: job failed", "error", err)
+		if err := m.Queue.Fail(ctx, job, err); err != nil {
+			log.Error("queue: recording failed job", "error", err)
+		}
+		return
+	}
+
+	delay := m.backoff(job.Attempts)
+	log.Warn("queue: job will be retried", "error", err, "delay", delay)
+	if err := m.Queue.Release(ctx, job, delay); err != nil {
+		log.Error("queue: releasing job", "error", err)
+	}
+}
+
+// run calls h, turning a panic into an error
+func (m *Manager) run(ctx context.Context, h Handler, job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, m.retryAfter())
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return h(ctx, job)
+}
+
+func (m *Manager) defaultQueue() string {
+	if m.DefaultQueue == "" {
+		return "default"
+	}
+	return m.DefaultQueue
+}
+
+func (m *Manager) maxAttempts() int {
+	if m.MaxAttempts < 1 {
+		return 3
+	}
+	return m.MaxAttempts
+}
+
+func (m *Manager) retryAfter() time.Duration {
+	if m.RetryAfter <= 0 {
+		return 90 * time.Second
+	}
+	return m.RetryAfter
+}
+
+func (m *Manager) pollInterval() time.Duration {
+	if m.PollInterval <= 0 {
+		return time.Second
+	}
+	return m.PollInterval
+}
+
+func (m *Manager) backoff(attempts int) time.Duration {
+	if m.Backoff == nil {
+		return ExponentialBackoff(10*time.Second, time.Hour)(attempts)
+	}
+	return m.Backoff(attempts)
+}
+
+func (m *Manager) logger() *slog.Logger {
+	if m.Logger == nil {
+		return slog.Default()
+	}
+	return m.Logger
+}
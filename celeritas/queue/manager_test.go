@@ -0,0 +1,179 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestManager(q Queue) *Manager {
+	return &Manager{
+		Queue:        q,
+		Concurrency:  4,
+		PollInterval: 10 * time.Millisecond,
+		Backoff:      func(int) time.Duration { return 0 },
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// work runs m until done is closed or the timeout passes
+func work(t *testing.T, m *Manager, done <-chan struct{}, queues ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		_ = m.Work(ctx, queues...)
+		close(finished)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for jobs")
+	}
+
+	cancel()
+	<-finished
+}
+
+func TestManager_Retry(t *testing.T) {
+	for name, q := range drivers() {
+		m := newTestManager(q)
+
+		var calls int32
+		done := make(chan struct{})
+		m.Handle("flaky", func(ctx context.Context, job *Job) error {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				return errors.New("try again")
+			}
+			close(done)
+			return nil
+		})
+
+		_, err := m.Dispatch(context.Background(), "flaky", nil, OnQueue("retry-"+name))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		work(t, m, done, "retry-"+name)
+
+		if calls != 3 {
+			t.Errorf("%s: expected 3 attempts, got %d", name, calls)
+		}
+
+		size, _ := q.Size(context.Background(), "retry-"+name)
+		if size != 0 {
+			t.Errorf("%s: job still on the queue after it succeeded", name)
+		}
+	}
+}
+
+func TestManager_Failed(t *testing.T) {
+	for name, q := range drivers() {
+		m := newTestManager(q)
+
+		var calls int32
+		m.Handle("broken", func(ctx context.Context, job *Job) error {
+			atomic.AddInt32(&calls, 1)
+			panic("broken")
+		})
+
+		job, err := m.Dispatch(context.Background(), "broken", nil, OnQueue("failed-"+name), MaxAttempts(2))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			for {
+				failed, _ := q.Failed(context.Background())
+				for _, f := range failed {
+					if f.ID == job.ID {
+						close(done)
+						return
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+
+		work(t, m, done, "failed-"+name)
+
+		if calls != 2 {
+			t.Errorf("%s: expected 2 attempts, got %d", name, calls)
+		}
+	}
+}
+
+func TestManager_Concurrency(t *testing.T) {
+	for name, q := range drivers() {
+		m := newTestManager(q)
+
+		var mu sync.Mutex
+		seen := make(map[int]int)
+		var wg sync.WaitGroup
+
+		m.Handle("count", func(ctx context.Context, job *Job) error {
+			var n int
+			err := job.Decode(&n)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			seen[n]++
+			mu.Unlock()
+			wg.Done()
+			return nil
+		})
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			_, err := m.Dispatch(context.Background(), "count", i, OnQueue("concurrency-"+name))
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		work(t, m, done, "concurrency-"+name)
+
+		for i := 0; i < 20; i++ {
+			if seen[i] != 1 {
+				t.Errorf("%s: job %d ran %d times", name, i, seen[i])
+			}
+		}
+	}
+}
+
+func TestManager_Delay(t *testing.T) {
+	m := newTestManager(&testRedisQueue)
+
+	var ran int32
+	m.Handle("later", func(ctx context.Context, job *Job) error {
+		atomic.StoreInt32(&ran, 1)
+		return nil
+	})
+
+	_, err := m.Dispatch(context.Background(), "later", nil, OnQueue("delay-manager"), Delay(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(done) })
+	work(t, m, done, "delay-manager")
+
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Error("delayed job ran early")
+	}
+}
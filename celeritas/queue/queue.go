@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Queue is the interface implemented by the queue drivers. A driver stores jobs until
+// they are due, hands each one to a single worker at a time, and keeps jobs that have
+// used up all of their attempts in a failed jobs store.
+type Queue interface {
+	// Push adds job to the queue named in job.Queue. It becomes available to workers
+	// at job.AvailableAt.
+	Push(ctx context.Context, job *Job) error
+	// Pop reserves the next available job on queue for reserveFor, and increments its
+	// attempts. It returns nil if no job is available. A job that is neither acknowledged,
+	// released nor failed before its reservation runs out becomes available again, so
+	// that jobs held by a worker that crashed are not lost.
+	Pop(ctx context.Context, queue string, reserveFor time.Duration) (*Job, error)
+	// Ack removes a reserved job that completed successfully
+	Ack(ctx context.Context, job *Job) error
+	// Release returns a reserved job to its queue, to be tried again after delay
+	Release(ctx context.Context, job *Job, delay time.Duration) error
+	// Fail removes a reserved job from its queue and records it as failed with reason
+	Fail(ctx context.Context, job *Job, reason error) error
+	// Failed returns the jobs that have failed, most recent first
+	Failed(ctx context.Context) ([]FailedJob, error)
+	// Size returns the number of jobs on queue that have not yet completed or failed
+	Size(ctx context.Context, queue string) (int, error)
+}
+
+// Job is a unit of work on a queue. Name selects the handler that runs it, and Payload
+// holds its JSON encoded arguments.
+type Job struct {
+	ID          string          `json:"id"`
+	Queue       string          `json:"queue"`
+	Name        string          `json:"name"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	AvailableAt time.Time       `json:"available_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Decode unmarshals the job's payload into v
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// FailedJob is a job that used up all of its attempts, along with the last error it returned
+type FailedJob struct {
+	Job
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Option changes how a job is dispatched
+type Option func(*Job)
+
+// OnQueue dispatches the job to the named queue instead of the default one
+func OnQueue(name string) Option {
+	return func(j *Job) {
+		j.Queue = name
+	}
+}
+
+// Delay makes the job available to workers only once d has passed
+func Delay(d time.Duration) Option {
+	return func(j *Job) {
+		j.AvailableAt = j.AvailableAt.Add(d)
+	}
+}
+
+// MaxAttempts sets how many times the job is tried before it is moved to the failed jobs
+func MaxAttempts(n int) Option {
+	return func(j *Job) {
+		j.MaxAttempts = n
+	}
+}
+
+// ExponentialBackoff returns a backoff function that waits base before the first retry,
+// and doubles the wait for every retry after that, up to max
+func ExponentialBackoff(base, max time.Duration) func(attempts int) time.Duration {
+	return func(attempts int) time.Duration {
+		d := base
+		for i := 1; i < attempts && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// newID returns a random job id
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
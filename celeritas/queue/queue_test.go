@@ -0,0 +1,218 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestJob(t *testing.T, queue string) *Job {
+	id, err := newID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	return &Job{
+		ID:          id,
+		Queue:       queue,
+		Name:        "test",
+		Payload:     []byte(`{"widget":1}`),
+		MaxAttempts: 3,
+		AvailableAt: now,
+		CreatedAt:   now,
+	}
+}
+
+func TestQueue_PushPopAck(t *testing.T) {
+	ctx := context.Background()
+
+	for name, q := range drivers() {
+		job := newTestJob(t, "push-pop")
+
+		err := q.Push(ctx, job)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		size, _ := q.Size(ctx, "push-pop")
+		if size != 1 {
+			t.Errorf("%s: expected size 1, got %d", name, size)
+		}
+
+		popped, err := q.Pop(ctx, "push-pop", time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if popped == nil || popped.ID != job.ID || popped.Attempts != 1 {
+			t.Fatalf("%s: wrong job popped: %+v", name, popped)
+		}
+
+		var payload struct{ Widget int }
+		err = popped.Decode(&payload)
+		if err != nil || payload.Widget != 1 {
+			t.Errorf("%s: payload not decoded: %v %+v", name, err, payload)
+		}
+
+		again, _ := q.Pop(ctx, "push-pop", time.Minute)
+		if again != nil {
+			t.Errorf("%s: reserved job popped twice", name)
+		}
+
+		err = q.Ack(ctx, popped)
+		if err != nil {
+			t.Error(name, err)
+		}
+
+		size, _ = q.Size(ctx, "push-pop")
+		if size != 0 {
+			t.Errorf("%s: expected empty queue after ack, got %d", name, size)
+		}
+	}
+}
+
+func TestQueue_NamesSharingAPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	for name, q := range drivers() {
+		high := newTestJob(t, "emails:high")
+
+		err := q.Push(ctx, high)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		// a job on emails:high is not on emails, whether it is waiting or reserved
+		popped, err := q.Pop(ctx, "emails", time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if popped != nil {
+			t.Errorf("%s: popped a job from emails:high off emails", name)
+		}
+
+		popped, _ = q.Pop(ctx, "emails:high", time.Minute)
+		if popped == nil || popped.ID != high.ID {
+			t.Fatalf("%s: expected the job on emails:high, got %+v", name, popped)
+		}
+
+		if size, _ := q.Size(ctx, "emails"); size != 0 {
+			t.Errorf("%s: expected emails to be empty, got %d", name, size)
+		}
+
+		_ = q.Ack(ctx, popped)
+	}
+}
+
+func TestQueue_Delay(t *testing.T) {
+	ctx := context.Background()
+
+	for name, q := range drivers() {
+		job := newTestJob(t, "delay")
+		job.AvailableAt = time.Now().Add(time.Hour)
+
+		err := q.Push(ctx, job)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		popped, err := q.Pop(ctx, "delay", time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if popped != nil {
+			t.Errorf("%s: delayed job popped early", name)
+		}
+	}
+}
+
+func TestQueue_ReleaseAndExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	for name, q := range drivers() {
+		err := q.Push(ctx, newTestJob(t, "release"))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		popped, _ := q.Pop(ctx, "release", time.Minute)
+		if popped == nil {
+			t.Fatalf("%s: no job popped", name)
+		}
+
+		err = q.Release(ctx, popped, 0)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		// a reservation that has run out makes the job available again, as if its worker died
+		popped, _ = q.Pop(ctx, "release", 0)
+		if popped == nil || popped.Attempts != 2 {
+			t.Fatalf("%s: released job not available again: %+v", name, popped)
+		}
+
+		popped, _ = q.Pop(ctx, "release", time.Minute)
+		if popped == nil || popped.Attempts != 3 {
+			t.Fatalf("%s: expired reservation not returned to the queue: %+v", name, popped)
+		}
+
+		_ = q.Ack(ctx, popped)
+	}
+}
+
+func TestQueue_Fail(t *testing.T) {
+	ctx := context.Background()
+
+	for name, q := range drivers() {
+		job := newTestJob(t, "fail")
+
+		err := q.Push(ctx, job)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		popped, _ := q.Pop(ctx, "fail", time.Minute)
+		if popped == nil {
+			t.Fatalf("%s: no job popped", name)
+		}
+
+		err = q.Fail(ctx, popped, errors.New("out of widgets"))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		size, _ := q.Size(ctx, "fail")
+		if size != 0 {
+			t.Errorf("%s: failed job still on the queue", name)
+		}
+
+		failed, err := q.Failed(ctx)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		found := false
+		for _, f := range failed {
+			if f.ID == job.ID && f.Error == "out of widgets" && f.Attempts == 1 {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: failed job not recorded: %+v", name, failed)
+		}
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := backoff(i + 1); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+}
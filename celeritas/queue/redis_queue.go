@@ -0,0 +1,201 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisQueue keeps each queue in a sorted set of job ids, scored by the time at which
+// they become available, with the jobs themselves in a hash. Reserved jobs move to a
+// second sorted set, scored by the time at which their reservation runs out. Keys start
+// with "queue:" and Prefix, so that emptying a cache that shares the pool leaves them alone.
+type RedisQueue struct {
+	Conn   *redis.Pool
+	Prefix string
+}
+
+// popScript moves expired reservations back onto the queue, and then reserves the first
+// job that is due and increments its attempts, all in one step so that two workers can
+// never take the same job
+var popScript = redis.NewScript(4, `
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+
+local id = ids[1]
+redis.call('ZREM', KEYS[1], id)
+redis.call('ZADD', KEYS[2], ARGV[2], id)
+local attempts = redis.call('HINCRBY', KEYS[4], id, 1)
+return {redis.call('HGET', KEYS[3], id), attempts}
+`)
+
+func (q *RedisQueue) key(queue, suffix string) string {
+	return fmt.Sprintf("queue:%s:%s%s", q.Prefix, queue, suffix)
+}
+
+func (q *RedisQueue) failedKey() string {
+	return fmt.Sprintf("failed_jobs:%s", q.Prefix)
+}
+
+// Push adds job to its queue
+func (q *RedisQueue) Push(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	conn, err := q.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	_ = conn.Send("HSET", q.key(job.Queue, ":jobs"), job.ID, data)
+	_ = conn.Send("HSET", q.key(job.Queue, ":attempts"), job.ID, job.Attempts)
+	_ = conn.Send("ZADD", q.key(job.Queue, ""), job.AvailableAt.UnixMilli(), job.ID)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+// Pop reserves the next available job on queue
+func (q *RedisQueue) Pop(ctx context.Context, queue string, reserveFor time.Duration) (*Job, error) {
+	conn, err := q.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	now := time.Now()
+	reply, err := redis.Values(popScript.Do(conn,
+		q.key(queue, ""), q.key(queue, ":reserved"), q.key(queue, ":jobs"), q.key(queue, ":attempts"),
+		now.UnixMilli(), now.Add(reserveFor).UnixMilli()))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	var attempts int
+	_, err = redis.Scan(reply, &data, &attempts)
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	err = json.Unmarshal(data, &job)
+	if err != nil {
+		return nil, err
+	}
+	job.Attempts = attempts
+
+	return &job, nil
+}
+
+// Ack removes a completed job
+func (q *RedisQueue) Ack(ctx context.Context, job *Job) error {
+	conn, err := q.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	q.sendRemove(conn, job)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+// sendRemove queues the commands that remove job, within a MULTI
+func (q *RedisQueue) sendRemove(conn redis.Conn, job *Job) {
+	_ = conn.Send("ZREM", q.key(job.Queue, ":reserved"), job.ID)
+	_ = conn.Send("HDEL", q.key(job.Queue, ":jobs"), job.ID)
+	_ = conn.Send("HDEL", q.key(job.Queue, ":attempts"), job.ID)
+}
+
+// Release returns a reserved job to its queue, to be tried again after delay
+func (q *RedisQueue) Release(ctx context.Context, job *Job, delay time.Duration) error {
+	conn, err := q.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	_ = conn.Send("ZREM", q.key(job.Queue, ":reserved"), job.ID)
+	_ = conn.Send("ZADD", q.key(job.Queue, ""), time.Now().Add(delay).UnixMilli(), job.ID)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+// Fail records a reserved job as failed and removes it from its queue
+func (q *RedisQueue) Fail(ctx context.Context, job *Job, reason error) error {
+	data, err := json.Marshal(FailedJob{Job: *job, Error: reason.Error(), FailedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	conn, err := q.Conn.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// recorded and removed together, so that a job is never both failed and still queued
+	_ = conn.Send("MULTI")
+	_ = conn.Send("LPUSH", q.failedKey(), data)
+	q.sendRemove(conn, job)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+// Failed returns the failed jobs, most recent first
+func (q *RedisQueue) Failed(ctx context.Context) ([]FailedJob, error) {
+	conn, err := q.Conn.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	items, err := redis.ByteSlices(conn.Do("LRANGE", q.failedKey(), 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []FailedJob
+	for _, item := range items {
+		var f FailedJob
+		err = json.Unmarshal(item, &f)
+		if err != nil {
+			return nil, err
+		}
+		failed = append(failed, f)
+	}
+
+	return failed, nil
+}
+
+// Size returns the number of waiting and reserved jobs on queue
+func (q *RedisQueue) Size(ctx context.Context, queue string) (int, error) {
+	conn, err := q.Conn.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return redis.Int(conn.Do("HLEN", q.key(queue, ":jobs")))
+}
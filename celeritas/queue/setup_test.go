@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testRedisQueue RedisQueue
+var testBadgerQueue BadgerQueue
+var testSQLQueue SQLQueue
+
+// drivers returns every queue driver under test, by name
+func drivers() map[string]Queue {
+	return map[string]Queue{
+		"redis":  &testRedisQueue,
+		"badger": &testBadgerQueue,
+		"sql":    &testSQLQueue,
+	}
+}
+
+func TestMain(m *testing.M) {
+	s, err := miniredis.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pool := redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+	testRedisQueue.Conn = &pool
+	testRedisQueue.Prefix = "test-celeritas"
+
+	dir, err := os.MkdirTemp("", "queue")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := badger.DefaultOptions(filepath.Join(dir, "badger"))
+	opts.Logger = nil
+	bdb, err := badger.Open(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	testBadgerQueue.Conn = bdb
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "queue.db")+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		create table jobs (
+			id varchar(64) primary key,
+			queue varchar(255) not null,
+			name varchar(255) not null,
+			payload text not null,
+			attempts integer not null default 0,
+			max_attempts integer not null default 1,
+			available_at integer not null,
+			reserved_until integer,
+			created_at integer not null
+		);
+		create table failed_jobs (
+			id varchar(64) primary key,
+			queue varchar(255) not null,
+			name varchar(255) not null,
+			payload text not null,
+			attempts integer not null,
+			error text not null,
+			failed_at integer not null
+		);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	testSQLQueue.DB = db
+	testSQLQueue.DBType = "sqlite"
+
+	code := m.Run()
+
+	_ = pool.Close()
+	s.Close()
+	_ = bdb.Close()
+	_ = db.Close()
+	_ = os.RemoveAll(dir)
+
+	os.Exit(code)
+}
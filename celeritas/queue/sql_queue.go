@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tschenhau/celeritas/sqlbind"
+)
+
+// SQLQueue keeps jobs in the jobs table, and failed jobs in the failed_jobs table, of
+// a postgres, mysql, mariadb or sqlite database. The tables are created by running
+// `celeritas make queue-tables`. Times are stored as unix seconds.
+type SQLQueue struct {
+	DB     *sql.DB
+	DBType string
+}
+
+// rebind converts ? placeholders to $1, $2... for postgres
+func (q *SQLQueue) rebind(query string) string {
+	return sqlbind.Rebind(q.DBType, query)
+}
+
+// Push adds job to its queue
+func (q *SQLQueue) Push(ctx context.Context, job *Job) error {
+	_, err := q.DB.ExecContext(ctx, q.rebind(`insert into jobs
+		(id, queue, name, payload, attempts, max_attempts, available_at, created_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?)`),
+		job.ID, job.Queue, job.Name, string(job.Payload), job.Attempts, job.MaxAttempts,
+		job.AvailableAt.Unix(), job.CreatedAt.Unix())
+	return err
+}
+
+// Pop reserves the next available job on queue. It picks a candidate and then claims it
+// with a conditional update, so that it works the same way on every database without
+// row locks; if another worker claims the candidate first, it tries the next one.
+func (q *SQLQueue) Pop(ctx context.Context, queue string, reserveFor time.Duration) (*Job, error) {
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		var id string
+		err := q.DB.QueryRowContext(ctx, q.rebind(`select id from jobs
+			where queue = ? and available_at <= ? and (reserved_until is null or reserved_until <= ?)
+			order by available_at, created_at limit 1`),
+			queue, now.Unix(), now.Unix()).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := q.DB.ExecContext(ctx, q.rebind(`update jobs
+			set attempts = attempts + 1, reserved_until = ?
+			where id = ? and available_at <= ? and (reserved_until is null or reserved_until <= ?)`),
+			now.Add(reserveFor).Unix(), id, now.Unix(), now.Unix())
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 1 {
+			return q.find(ctx, id)
+		}
+	}
+
+	return nil, nil
+}
+
+// find returns the job with the given id
+func (q *SQLQueue) find(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	var payload string
+	var availableAt, createdAt int64
+
+	err := q.DB.QueryRowContext(ctx, q.rebind(`select id, queue, name, payload, attempts, max_attempts,
+		available_at, created_at from jobs where id = ?`), id).Scan(
+		&job.ID, &job.Queue, &job.Name, &payload, &job.Attempts, &job.MaxAttempts, &availableAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = []byte(payload)
+	job.AvailableAt = time.Unix(availableAt, 0)
+	job.CreatedAt = time.Unix(createdAt, 0)
+
+	return &job, nil
+}
+
+// Ack removes a completed job
+func (q *SQLQueue) Ack(ctx context.Context, job *Job) error {
+	_, err := q.DB.ExecContext(ctx, q.rebind(`delete from jobs where id = ?`), job.ID)
+	return err
+}
+
+// Release returns a reserved job to its queue, to be tried again after delay
+func (q *SQLQueue) Release(ctx context.Context, job *Job, delay time.Duration) error {
+	_, err := q.DB.ExecContext(ctx, q.rebind(`update jobs set reserved_until = null, available_at = ? where id = ?`),
+		time.Now().Add(delay).Unix(), job.ID)
+	return err
+}
+
+// Fail moves a reserved job to the failed_jobs table
+func (q *SQLQueue) Fail(ctx context.Context, job *Job, reason error) error {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, q.rebind(`insert into failed_jobs
+		(id, queue, name, payload, attempts, error, failed_at)
+		values (?, ?, ?, ?, ?, ?, ?)`),
+		job.ID, job.Queue, job.Name, string(job.Payload), job.Attempts, reason.Error(), time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, q.rebind(`delete from jobs where id = ?`), job.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Failed returns the failed jobs, most recent first
+func (q *SQLQueue) Failed(ctx context.Context) ([]FailedJob, error) {
+	rows, err := q.DB.QueryContext(ctx, `select id, queue, name, payload, attempts, error, failed_at
+		from failed_jobs order by failed_at desc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failed []FailedJob
+	for rows.Next() {
+		var f FailedJob
+		var payload string
+		var failedAt int64
+
+		err = rows.Scan(&f.ID, &f.Queue, &f.Name, &payload, &f.Attempts, &f.Error, &failedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		f.Payload = []byte(payload)
+		f.FailedAt = time.Unix(failedAt, 0)
+		failed = append(failed, f)
+	}
+
+	return failed, rows.Err()
+}
+
+// Size returns the number of waiting and reserved jobs on queue
+func (q *SQLQueue) Size(ctx context.Context, queue string) (int, error) {
+	var n int
+	err := q.DB.QueryRowContext(ctx, q.rebind(`select count(*) from jobs where queue = ?`), queue).Scan(&n)
+	return n, err
+}
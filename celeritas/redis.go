@@ -0,0 +1,96 @@
+package celeritas
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// createRedisPool returns the pool of redis connections shared by the cache, sessions and
+// queue, set up from c.Config.Redis
+func (c *Celeritas) createRedisPool() (*redis.Pool, error) {
+	cfg := c.Config.Redis
+
+	options := []redis.DialOption{
+		redis.DialDatabase(cfg.DB),
+		redis.DialConnectTimeout(cfg.DialTimeout),
+		redis.DialReadTimeout(cfg.ReadTimeout),
+		redis.DialWriteTimeout(cfg.WriteTimeout),
+	}
+	if cfg.Username != "" {
+		options = append(options, redis.DialUsername(cfg.Username))
+	}
+	if cfg.Password != "" {
+		options = append(options, redis.DialPassword(cfg.Password))
+	}
+
+	if cfg.TLS {
+		tlsConfig, err := c.redisTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, redis.DialUseTLS(true), redis.DialTLSConfig(tlsConfig),
+			redis.DialTLSSkipVerify(cfg.TLSSkipVerify))
+	}
+
+	return &redis.Pool{
+		MaxIdle:     cfg.MaxIdle,
+		MaxActive:   cfg.MaxActive,
+		IdleTimeout: cfg.IdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", cfg.Host, options...)
+		},
+
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			_, err := conn.Do("PING")
+			return err
+		},
+	}, nil
+}
+
+// redisTLSConfig returns the tls settings for connecting to redis. The server's certificate
+// is checked against the certificates in REDIS_TLS_CA, if it is set, and its name against
+// REDIS_TLS_SERVER_NAME, or the host name in REDIS_HOST.
+func (c *Celeritas) redisTLSConfig() (*tls.Config, error) {
+	cfg := c.Config.Redis
+
+	serverName := cfg.TLSServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(cfg.Host)
+		if err != nil {
+			host = cfg.Host
+		}
+		serverName = host
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.TLSCA != "" {
+		path := cfg.TLSCA
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.RootPath, path)
+		}
+
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading REDIS_TLS_CA: %w", err)
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("REDIS_TLS_CA %s holds no pem encoded certificates", cfg.TLSCA)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	return tlsConfig, nil
+}
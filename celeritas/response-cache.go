@@ -0,0 +1,209 @@
+package celeritas
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/CloudyKit/jet/v6"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/tschenhau/celeritas/cache"
+)
+
+// Cached responses and fragments are stored under these prefixes, so that they can be
+// emptied without touching the rest of the cache
+const (
+	responseCachePrefix = "response:"
+	fragmentCachePrefix = "fragment:"
+)
+
+// cachedResponse is a response as it is stored in the cache
+type cachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+func init() {
+	gob.Register(cachedResponse{})
+}
+
+// CacheResponse returns middleware that keeps successful GET responses in c.Cache for ttl
+// seconds, so that the handler only runs again once they expire. Responses are stored by
+// host, path and query string, and by the values of the request headers named in vary,
+// such as Accept-Language. Responses served from the cache have an X-Cache: HIT header, and
+// the rest X-Cache: MISS.
+//
+// Requests from logged in users, with a userID in their session, bypass the cache, and
+// responses that set cookies or are marked private or no-store are not kept. Pages holding a
+// CSRF token, such as pages with forms, should not be cached. Use it on routes with chi's With:
+//
+//	a.App.Routes.With(a.App.CacheResponse(600, "Accept-Language")).Get("/pricing", a.Handlers.Pricing)
+//
+// It panics if ttl is not greater than zero, since the cache drivers cannot store an entry
+// that has already expired, and every request would miss.
+func (c *Celeritas) CacheResponse(ttl int, vary ...string) func(http.Handler) http.Handler {
+	if ttl <= 0 {
+		panic(fmt.Sprintf("celeritas: CacheResponse needs a ttl greater than zero, got %d", ttl))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || c.Cache == nil || c.sessionHas(r, "userID") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := responseCacheKey(r, vary)
+
+			var cached cachedResponse
+			err := c.Cache.Scan(key, &cached)
+			if err == nil {
+				for name, values := range cached.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("X-Cache", "HIT")
+				w.WriteHeader(cached.Status)
+				_, _ = w.Write(cached.Body)
+				return
+			}
+			if !errors.Is(err, cache.ErrCacheMiss) {
+				c.RequestLogger(r).Error("reading cached response", "error", err)
+			}
+
+			var body bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&body)
+
+			w.Header().Set("X-Cache", "MISS")
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if status != http.StatusOK || !cacheable(w.Header()) {
+				return
+			}
+
+			header := w.Header().Clone()
+			header.Del("X-Cache")
+
+			err = c.Cache.Set(key, cachedResponse{Status: status, Header: header, Body: body.Bytes()}, ttl)
+			if err != nil {
+				c.RequestLogger(r).Error("caching response", "error", err)
+			}
+		})
+	}
+}
+
+// FlushResponseCache removes every response stored by CacheResponse, and every fragment
+// stored by the cache template function
+func (c *Celeritas) FlushResponseCache() error {
+	err := c.Cache.EmptyByMatch(responseCachePrefix)
+	if err != nil {
+		return err
+	}
+	return c.Cache.EmptyByMatch(fragmentCachePrefix)
+}
+
+// responseCacheKey returns the key r's response is stored under
+func responseCacheKey(r *http.Request, vary []string) string {
+	h := sha1.New()
+	h.Write([]byte(r.Host + r.URL.Path + "?" + r.URL.Query().Encode()))
+	for _, name := range vary {
+		h.Write([]byte("\n" + strings.ToLower(name) + ":" + strings.Join(r.Header.Values(name), ",")))
+	}
+	return responseCachePrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheable reports whether a response with these headers may be shared between users
+func cacheable(header http.Header) bool {
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	control := strings.ToLower(header.Get("Cache-Control"))
+	return !strings.Contains(control, "no-store") && !strings.Contains(control, "private")
+}
+
+// sessionHas reports whether the session for r holds key. It is false when the session was
+// not loaded for r.
+func (c *Celeritas) sessionHas(r *http.Request, key string) (exists bool) {
+	if c.Session == nil {
+		return false
+	}
+
+	// scs panics if the session was not loaded for this request
+	defer func() {
+		if recover() != nil {
+			exists = false
+		}
+	}()
+
+	return c.Session.Exists(r.Context(), key)
+}
+
+// jetCache is the cache template function. It renders a block once, and then serves the
+// output from c.Cache for ttl seconds:
+//
+//	{{ block pricingTable() }} ... {{ end }}
+//	{{ cache("pricing-table", 600, "pricingTable") }}
+//
+// Blocks are usually defined in a file brought in with import, or in a template that extends
+// a layout, so that they are not also rendered where they are defined. The block sees the
+// variables and context of the template that calls cache. With a ttl of zero or less, the
+// block is rendered every time, and not cached.
+func (c *Celeritas) jetCache(a jet.Arguments) reflect.Value {
+	a.RequireNumOfArguments("cache", 3, 3)
+
+	var key, block string
+	var ttl int
+	if err := a.ParseInto(&key, &ttl, &block); err != nil {
+		a.Panicf("cache: %s", err)
+	}
+	key = fragmentCachePrefix + key
+
+	return reflect.ValueOf(jet.RendererFunc(func(r *jet.Runtime) {
+		if ttl <= 0 {
+			_, _ = r.Writer.Write(renderBlock(r, block))
+			return
+		}
+
+		fragment, err := cache.GetAs[string](c.Cache, key)
+		if err == nil {
+			_, _ = r.Writer.Write([]byte(fragment))
+			return
+		}
+
+		rendered := renderBlock(r, block)
+		_, _ = r.Writer.Write(rendered)
+
+		err = c.Cache.Set(key, string(rendered), ttl)
+		if err != nil && c.Logger != nil {
+			c.Logger.Error("caching template fragment", "key", key, "error", err)
+		}
+	}))
+}
+
+// renderBlock renders the block called name, and returns its output rather than writing it
+func renderBlock(r *jet.Runtime, name string) []byte {
+	var rendered bytes.Buffer
+
+	out := r.Writer
+	r.Writer = &rendered
+	defer func() {
+		r.Writer = out
+	}()
+
+	r.YieldBlock(name, nil)
+
+	return rendered.Bytes()
+}
@@ -0,0 +1,200 @@
+package celeritas
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CloudyKit/jet/v6"
+)
+
+// newCachingApp returns an application with a memory cache and cookie sessions
+func newCachingApp(t *testing.T) *Celeritas {
+	cfg := DefaultConfig()
+	cfg.Key = strings.Repeat("k", 32)
+
+	c := &Celeritas{}
+	err := c.NewWithConfig(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestCeleritas_CacheResponse(t *testing.T) {
+	c := newCachingApp(t)
+
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Query().Get("cookie") != "" {
+			http.SetCookie(w, &http.Cookie{Name: "seen", Value: "yes"})
+		}
+		_, _ = w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	})
+	h := c.SessionLoad(c.CacheResponse(60, "Accept-Language")(handler))
+
+	get := func(target, lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept-Language", lang)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/pricing?a=1&b=2", "en")
+	if rr.Header().Get("X-Cache") != "MISS" || calls != 1 {
+		t.Errorf("expected a miss, got %q after %d calls", rr.Header().Get("X-Cache"), calls)
+	}
+
+	// the order of the query string does not matter
+	rr = get("/pricing?b=2&a=1", "en")
+	if rr.Header().Get("X-Cache") != "HIT" || calls != 1 {
+		t.Errorf("expected a hit, got %q after %d calls", rr.Header().Get("X-Cache"), calls)
+	}
+	if rr.Body.String() != "hello en" || rr.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("cached response differs: %q %v", rr.Body.String(), rr.Header())
+	}
+
+	rr = get("/pricing?a=1&b=2", "fr")
+	if rr.Header().Get("X-Cache") != "MISS" || rr.Body.String() != "hello fr" {
+		t.Errorf("expected the vary header to give another entry, got %q %q", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+
+	// responses that set cookies are not kept
+	get("/pricing?cookie=1", "en")
+	rr = get("/pricing?cookie=1", "en")
+	if rr.Header().Get("X-Cache") != "MISS" {
+		t.Error("response setting a cookie was cached")
+	}
+
+	err := c.FlushResponseCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = get("/pricing?a=1&b=2", "en")
+	if rr.Header().Get("X-Cache") != "MISS" {
+		t.Error("response still cached after flushing")
+	}
+}
+
+func TestCeleritas_CacheResponse_Authenticated(t *testing.T) {
+	c := newCachingApp(t)
+
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte("private"))
+	})
+
+	login := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Session.Put(r.Context(), "userID", 1)
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	h := c.SessionLoad(login(c.CacheResponse(60)(handler)))
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/account", nil))
+
+		if rr.Header().Get("X-Cache") != "" {
+			t.Errorf("logged in request went through the cache: %q", rr.Header().Get("X-Cache"))
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected the handler to run for every logged in request, ran %d times", calls)
+	}
+}
+
+func TestCeleritas_JetCache(t *testing.T) {
+	c := newCachingApp(t)
+
+	views := filepath.Join(c.RootPath, "views")
+	_ = os.MkdirAll(views, 0755)
+	_ = os.WriteFile(filepath.Join(views, "blocks.jet"), []byte(`{{ block counter() }}<b>{{ .Count }}</b>{{ end }}`), 0644)
+	_ = os.WriteFile(filepath.Join(views, "page.jet"), []byte(`{{ import "./blocks.jet" }}[{{ cache("counter", 60, "counter") }}]`), 0644)
+
+	render := func(count int) string {
+		tmpl, err := c.JetViews.GetTemplate("page.jet")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		err = tmpl.Execute(&out, jet.VarMap{}, struct{ Count int }{count})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	if out := render(1); out != "[<b>1</b>]" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	// the fragment is served from the cache, so the new count is not rendered
+	if out := render(2); out != "[<b>1</b>]" {
+		t.Errorf("expected the cached fragment, got %q", out)
+	}
+
+	_ = c.FlushResponseCache()
+
+	if out := render(3); out != "[<b>3</b>]" {
+		t.Errorf("expected the fragment to be rendered again after flushing, got %q", out)
+	}
+}
+
+func TestCeleritas_CacheResponse_NoTTL(t *testing.T) {
+	c := newCachingApp(t)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected CacheResponse to panic without a ttl")
+		}
+	}()
+
+	c.CacheResponse(0)
+}
+
+func TestCeleritas_JetCache_NoTTL(t *testing.T) {
+	c := newCachingApp(t)
+
+	views := filepath.Join(c.RootPath, "views")
+	_ = os.MkdirAll(views, 0755)
+	_ = os.WriteFile(filepath.Join(views, "blocks.jet"), []byte(`{{ block counter() }}<b>{{ .Count }}</b>{{ end }}`), 0644)
+	_ = os.WriteFile(filepath.Join(views, "uncached.jet"), []byte(`{{ import "./blocks.jet" }}[{{ cache("uncached", 0, "counter") }}]`), 0644)
+
+	for count := 1; count <= 2; count++ {
+		tmpl, err := c.JetViews.GetTemplate("uncached.jet")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		err = tmpl.Execute(&out, jet.VarMap{}, struct{ Count int }{count})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// rendered every time, rather than stored with a ttl the cache would reject
+		if want := fmt.Sprintf("[<b>%d</b>]", count); out.String() != want {
+			t.Errorf("expected %q, got %q", want, out.String())
+		}
+	}
+
+	if _, err := c.Cache.Get(fragmentCachePrefix + "uncached"); err == nil {
+		t.Error("expected nothing to be cached with a ttl of 0")
+	}
+}
@@ -11,12 +11,25 @@ func (c *Celeritas) routes() http.Handler {
 	mux := chi.NewRouter()
 	mux.Use(middleware.RequestID)
 	mux.Use(middleware.RealIP)
-	if c.Debug {
-		mux.Use(middleware.Logger)
-	}
 	mux.Use(middleware.Recoverer)
 	mux.Use(c.SessionLoad)
+	if c.Config.Log.Access {
+		mux.Use(c.AccessLog)
+	}
 	mux.Use(c.NoSurf)
 
 	return mux
 }
+
+// mailPreviewPath is where the mail previews and inbox are served in debug mode
+const mailPreviewPath = "/_mail"
+
+// mountMailPreview serves the mail template previews, and the messages kept by the file
+// transport, at mailPreviewPath. chi builds the middleware chain when the first route is
+// added, so this must not be called before the session is created.
+func (c *Celeritas) mountMailPreview(mux *chi.Mux) {
+	mux.Get(mailPreviewPath, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, mailPreviewPath+"/", http.StatusMovedPermanently)
+	})
+	mux.Mount(mailPreviewPath+"/", http.StripPrefix(mailPreviewPath, c.Mail.PreviewHandler()))
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestBadgerStore(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "celeritas",
+		SessionType:    "badger",
+		BadgerConn:     db,
+	}
+
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, ok := ses.Store.(*BadgerStore)
+	if !ok {
+		t.Fatalf("wrong store returned for badger session; got %T", ses.Store)
+	}
+
+	err = store.Commit("abc", []byte("some data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.Find("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || string(b) != "some data" {
+		t.Error("session data not found in badger store")
+	}
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || string(all["abc"]) != "some data" {
+		t.Errorf("expected one session from All, got %v", all)
+	}
+
+	// a session committed with an expiry in the past is removed
+	err = store.Commit("abc", []byte("some data"), time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, err = store.Find("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Error("expected an expired session to be removed")
+	}
+
+	err = store.Commit("def", []byte("more data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Delete("def")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, _ = store.Find("def")
+	if found {
+		t.Error("expected a deleted session not to be found")
+	}
+}
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// BadgerStore keeps sessions in a badger database, under keys starting with Prefix, as the
+// scs badgerstore does. Badger removes them once they expire.
+type BadgerStore struct {
+	Conn   *badger.DB
+	Prefix string
+}
+
+// NewBadgerStore returns a store for sessions in db, under the prefix scs:session:
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{Conn: db, Prefix: "scs:session:"}
+}
+
+// Find returns the data for the session token, and whether it was found
+func (s *BadgerStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	err := s.Conn.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(s.Prefix + token))
+		if err != nil {
+			return err
+		}
+
+		b, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit stores b as the data for the session token until expiry
+func (s *BadgerStore) Commit(token string, b []byte, expiry time.Time) error {
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return s.Delete(token)
+	}
+
+	return s.Conn.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry([]byte(s.Prefix+token), b).WithTTL(ttl))
+	})
+}
+
+// Delete removes the session token
+func (s *BadgerStore) Delete(token string) error {
+	return s.Conn.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(s.Prefix + token))
+	})
+}
+
+// All returns the data of every session that has not expired, by token
+func (s *BadgerStore) All() (map[string][]byte, error) {
+	sessions := make(map[string][]byte)
+
+	err := s.Conn.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(s.Prefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			b, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			sessions[string(it.Item().Key()[len(prefix):])] = b
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
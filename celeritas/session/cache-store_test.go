@@ -0,0 +1,63 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tschenhau/celeritas/cache"
+)
+
+func TestCacheStore(t *testing.T) {
+	mc, err := cache.NewMemoryCache(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "celeritas",
+		SessionType:    "cache",
+		Cache:          mc,
+	}
+
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, ok := ses.Store.(*CacheStore)
+	if !ok {
+		t.Fatalf("wrong store returned for cache session; got %T", ses.Store)
+	}
+
+	err = store.Commit("abc", []byte("some data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.Find("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || string(b) != "some data" {
+		t.Error("session data not found in cache store")
+	}
+
+	has, _ := mc.Has("session:abc")
+	if !has {
+		t.Error("expected the session to be cached under session:abc")
+	}
+
+	err = store.Delete("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, err = store.Find("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Error("expected a deleted session not to be found")
+	}
+}
@@ -0,0 +1,52 @@
+package session
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/tschenhau/celeritas/cache"
+)
+
+// CacheStore keeps sessions in any cache.Cache, under keys starting with Prefix. Sessions
+// are only as durable as the cache: a memory cache loses them on restart, or when it evicts
+// them to make room.
+type CacheStore struct {
+	Cache  cache.Cache
+	Prefix string
+}
+
+// NewCacheStore returns a store for sessions in c, under the prefix session:
+func NewCacheStore(c cache.Cache) *CacheStore {
+	return &CacheStore{Cache: c, Prefix: "session:"}
+}
+
+// Find returns the data for the session token, and whether it was found
+func (s *CacheStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	err := s.Cache.Scan(s.Prefix+token, &b)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit stores b as the data for the session token until expiry, rounded up to the second
+func (s *CacheStore) Commit(token string, b []byte, expiry time.Time) error {
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return s.Delete(token)
+	}
+
+	return s.Cache.Set(s.Prefix+token, b, int(math.Ceil(ttl.Seconds())))
+}
+
+// Delete removes the session token
+func (s *CacheStore) Delete(token string) error {
+	return s.Cache.Forget(s.Prefix + token)
+}
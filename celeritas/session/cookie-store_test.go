@@ -0,0 +1,242 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// newCookieSession returns a session manager keeping sessions in cookies sealed with keys, and
+// a handler that stores the value query parameter in the session, or reads it back
+func newCookieSession(t *testing.T, keys ...string) (*scs.SessionManager, http.Handler) {
+	t.Helper()
+
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "celeritas",
+		SessionType:    "cookie",
+		EncryptionKeys: keys,
+	}
+
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, ok := ses.Store.(*CookieStore)
+	if !ok {
+		t.Fatalf("wrong store returned for cookie session; got %T", ses.Store)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Has("value"):
+			ses.Put(r.Context(), "value", r.URL.Query().Get("value"))
+		case r.URL.Query().Has("destroy"):
+			_ = ses.Destroy(r.Context())
+		}
+		_, _ = w.Write([]byte(ses.GetString(r.Context(), "value")))
+	})
+
+	return ses, store.LoadAndSave(ses, handler)
+}
+
+// serve makes a request to h with cookie, if it is not nil, and returns the response
+func serve(h http.Handler, target string, cookie *http.Cookie) *http.Response {
+	req := httptest.NewRequest("GET", target, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	return rr.Result()
+}
+
+func sessionCookie(t *testing.T, res *http.Response) *http.Cookie {
+	t.Helper()
+
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "celeritas" {
+			return cookie
+		}
+	}
+
+	t.Fatal("no session cookie in the response")
+	return nil
+}
+
+func body(res *http.Response) string {
+	b, _ := io.ReadAll(res.Body)
+	return string(b)
+}
+
+func TestCookieStore_RoundTrip(t *testing.T) {
+	_, h := newCookieSession(t, strings.Repeat("k", 32))
+
+	cookie := sessionCookie(t, serve(h, "/?value=secret", nil))
+
+	if strings.Contains(cookie.Value, "secret") {
+		t.Error("expected the session to be encrypted")
+	}
+
+	res := serve(h, "/", cookie)
+	if got := body(res); got != "secret" {
+		t.Errorf("expected the session to be read from the cookie, got %q", got)
+	}
+
+	// an unchanged session is not written again
+	if len(res.Cookies()) != 0 {
+		t.Error("expected no cookie for an unchanged session")
+	}
+
+	// a destroyed session removes the cookie
+	removed := sessionCookie(t, serve(h, "/?destroy", cookie))
+	if removed.Value != "" || removed.MaxAge >= 0 {
+		t.Errorf("expected the cookie to be removed, got %+v", removed)
+	}
+}
+
+func TestCookieStore_Tampered(t *testing.T) {
+	_, h := newCookieSession(t, strings.Repeat("k", 32))
+
+	cookie := sessionCookie(t, serve(h, "/?value=secret", nil))
+
+	// change one character of the sealed session
+	value := []byte(cookie.Value)
+	i := len(value) / 2
+	if value[i] == 'A' {
+		value[i] = 'B'
+	} else {
+		value[i] = 'A'
+	}
+
+	for _, tampered := range []string{string(value), cookie.Value[:len(cookie.Value)-1], "garbage", ""} {
+		got := body(serve(h, "/", &http.Cookie{Name: "celeritas", Value: tampered}))
+		if got != "" {
+			t.Errorf("expected a changed cookie to start a new session, got %q", got)
+		}
+	}
+
+	// a cookie sealed with another key is not read
+	_, other := newCookieSession(t, strings.Repeat("x", 32))
+	if got := body(serve(other, "/", cookie)); got != "" {
+		t.Errorf("expected a cookie sealed with another key to start a new session, got %q", got)
+	}
+
+	// nor is one sealed for a cookie of another name
+	store, err := NewCookieStore("other", strings.Repeat("k", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Find(cookie.Value); found {
+		t.Error("expected a value sealed for another cookie not to be found")
+	}
+}
+
+func TestCookieStore_Expired(t *testing.T) {
+	store, err := NewCookieStore("celeritas", strings.Repeat("k", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := store.seal([]byte("data"), time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found, _ := store.Find(value); found {
+		t.Error("expected an expired session not to be found")
+	}
+}
+
+func TestCookieStore_TooLarge(t *testing.T) {
+	ses, h := newCookieSession(t, strings.Repeat("k", 32))
+
+	var sessionErr error
+	ses.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		sessionErr = err
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	res := serve(h, "/?value="+strings.Repeat("a", CookieMaxSize), nil)
+
+	if !errors.Is(sessionErr, ErrCookieTooLarge) {
+		t.Errorf("expected ErrCookieTooLarge, got %v", sessionErr)
+	}
+	if res.StatusCode != http.StatusInternalServerError || len(res.Cookies()) != 0 {
+		t.Errorf("expected an error and no cookie, got %d and %d cookies", res.StatusCode, len(res.Cookies()))
+	}
+	if b := body(res); b != "" {
+		t.Errorf("expected only the error response, got the handler's %d bytes too", len(b))
+	}
+}
+
+func TestCookieStore_ChangedAfterWrite(t *testing.T) {
+	ses, _ := newCookieSession(t, strings.Repeat("k", 32))
+	store := ses.Store.(*CookieStore)
+
+	h := store.LoadAndSave(ses, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("written"))
+		ses.Put(r.Context(), "value", "after")
+	}))
+
+	res := serve(h, "/", nil)
+	if res.StatusCode != http.StatusCreated || body(res) != "written" {
+		t.Errorf("expected the handler's response, got %d", res.StatusCode)
+	}
+
+	b, found, _ := store.Find(sessionCookie(t, res).Value)
+	if !found {
+		t.Fatal("expected the session to be saved")
+	}
+	if _, values, _ := ses.Codec.Decode(b); values["value"] != "after" {
+		t.Errorf("expected the change made after writing to be kept, got %v", values)
+	}
+}
+
+func TestCookieStore_KeyRotation(t *testing.T) {
+	oldKey, newKey := strings.Repeat("o", 32), strings.Repeat("n", 32)
+
+	_, before := newCookieSession(t, oldKey)
+	cookie := sessionCookie(t, serve(before, "/?value=secret", nil))
+
+	// with the old key kept as a previous key, the session is read, and sealed again with the
+	// new one even though it has not changed
+	_, rotated := newCookieSession(t, newKey, oldKey)
+	res := serve(rotated, "/", cookie)
+	if got := body(res); got != "secret" {
+		t.Errorf("expected a session sealed with the previous key to be read, got %q", got)
+	}
+	resealed := sessionCookie(t, res)
+
+	// once the old key is dropped, only the resealed cookie is read
+	_, after := newCookieSession(t, newKey)
+	if got := body(serve(after, "/", cookie)); got != "" {
+		t.Errorf("expected a session sealed with a dropped key not to be read, got %q", got)
+	}
+	if got := body(serve(after, "/", resealed)); got != "secret" {
+		t.Errorf("expected the resealed session to be read, got %q", got)
+	}
+}
+
+func TestCookieStore_NeedsKey(t *testing.T) {
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "celeritas",
+		SessionType:    "cookie",
+	}
+
+	_, err := c.InitSession()
+	if err == nil {
+		t.Error("expected an error for cookie sessions without an encryption key")
+	}
+}
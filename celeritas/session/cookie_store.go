@@ -0,0 +1,290 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// CookieMaxSize is the largest cookie, counting its name and value, that every browser keeps
+const CookieMaxSize = 4096
+
+// ErrCookieTooLarge is returned when a session, once sealed, does not fit in its cookie
+var ErrCookieTooLarge = errors.New("session: the session is too large to keep in a cookie")
+
+var errCookieMiddleware = errors.New("session: cookie sessions are saved by the LoadAndSave middleware of the cookie store")
+
+// CookieStore keeps each session in its cookie, rather than on the server. The session is
+// encrypted with AES-GCM and signed with HMAC-SHA256, so the browser can neither read nor
+// change it, and it carries its expiry, so an old cookie stops working when the session
+// would have expired. Since the server keeps nothing, a session cannot be revoked before
+// then, and it must fit in the CookieMaxSize bytes of one cookie.
+//
+// The store needs its own middleware, LoadAndSave, which writes the sealed session into the
+// cookie in place of the token the session manager would write.
+type CookieStore struct {
+	Name string // the cookie's name, signed along with its value
+	keys []cookieKey
+}
+
+// cookieKey holds the encryption and signing keys derived from one key
+type cookieKey struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+// NewCookieStore returns a store for sessions kept in the cookie called name. Sessions are
+// sealed with the first key; the others, which the first has replaced, are only used to open
+// the sessions sealed before it did, which are sealed again with the first.
+func NewCookieStore(name string, keys ...string) (*CookieStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session: cookie sessions need an encryption key")
+	}
+
+	s := &CookieStore{Name: name}
+
+	for _, key := range keys {
+		if key == "" {
+			return nil, errors.New("session: cookie sessions need an encryption key")
+		}
+
+		block, err := aes.NewCipher(deriveKey(key, "celeritas session encryption"))
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		s.keys = append(s.keys, cookieKey{aead: aead, mac: deriveKey(key, "celeritas session signing")})
+	}
+
+	return s, nil
+}
+
+// deriveKey returns a 32 byte key for purpose, so that one key is never used for two things
+func deriveKey(key, purpose string) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(purpose))
+	return h.Sum(nil)
+}
+
+// seal encrypts b and its expiry with the first key, and signs the result
+func (s *CookieStore) seal(b []byte, expiry time.Time) (string, error) {
+	key := s.keys[0]
+
+	plaintext := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint64(plaintext, uint64(expiry.Unix()))
+	plaintext = append(plaintext, b...)
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := key.aead.Seal(nonce, nonce, plaintext, nil)
+	sealed = append(sealed, s.sign(key, sealed)...)
+
+	value := base64.RawURLEncoding.EncodeToString(sealed)
+	if size := len(s.Name) + 1 + len(value); size > CookieMaxSize {
+		return "", fmt.Errorf("%w: it takes %d bytes, and the limit is %d", ErrCookieTooLarge, size, CookieMaxSize)
+	}
+
+	return value, nil
+}
+
+// open returns the session sealed in value, and the index of the key that opened it. It
+// reports false if no key opens it, if it has been changed, or if it has expired.
+func (s *CookieStore) open(value string) ([]byte, int, bool) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	for i, key := range s.keys {
+		if len(sealed) < key.aead.NonceSize()+key.aead.Overhead()+8+sha256.Size {
+			return nil, 0, false
+		}
+
+		data, signature := sealed[:len(sealed)-sha256.Size], sealed[len(sealed)-sha256.Size:]
+		if !hmac.Equal(signature, s.sign(key, data)) {
+			continue
+		}
+
+		nonce, ciphertext := data[:key.aead.NonceSize()], data[key.aead.NonceSize():]
+		plaintext, err := key.aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return nil, 0, false
+		}
+
+		expiry := time.Unix(int64(binary.BigEndian.Uint64(plaintext)), 0)
+		if !time.Now().Before(expiry) {
+			return nil, 0, false
+		}
+
+		return plaintext[8:], i, true
+	}
+
+	return nil, 0, false
+}
+
+// sign returns the signature of data, as the value of the store's cookie
+func (s *CookieStore) sign(key cookieKey, data []byte) []byte {
+	h := hmac.New(sha256.New, key.mac)
+	h.Write([]byte(s.Name + "="))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// sealedCookie carries a session through a request handled by LoadAndSave: whether it was
+// sealed with an earlier key, and the value to write once it is committed
+type sealedCookie struct {
+	stale bool
+	value string
+}
+
+type sealedCookieKey struct{}
+
+// Find returns the session sealed in token, the value of the cookie
+func (s *CookieStore) Find(token string) ([]byte, bool, error) {
+	b, _, found := s.open(token)
+	return b, found, nil
+}
+
+// FindCtx returns the session sealed in token, and notes whether it needs sealing again with
+// the current key
+func (s *CookieStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	b, key, found := s.open(token)
+	if sealed, ok := ctx.Value(sealedCookieKey{}).(*sealedCookie); ok && found {
+		sealed.stale = key > 0
+	}
+	return b, found, nil
+}
+
+// Commit fails, since a session can only be written to a cookie by LoadAndSave
+func (s *CookieStore) Commit(token string, b []byte, expiry time.Time) error {
+	return errCookieMiddleware
+}
+
+// CommitCtx seals b, for LoadAndSave to write into the cookie. It returns ErrCookieTooLarge
+// if the result does not fit.
+func (s *CookieStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	sealed, ok := ctx.Value(sealedCookieKey{}).(*sealedCookie)
+	if !ok {
+		return errCookieMiddleware
+	}
+
+	value, err := s.seal(b, expiry)
+	if err != nil {
+		return err
+	}
+
+	sealed.value = value
+	return nil
+}
+
+// Delete does nothing: the session is removed when its cookie is
+func (s *CookieStore) Delete(token string) error {
+	return nil
+}
+
+// DeleteCtx does nothing, as Delete
+func (s *CookieStore) DeleteCtx(ctx context.Context, token string) error {
+	return nil
+}
+
+// LoadAndSave loads the session sealed in the request's cookie, and writes the session back
+// into the cookie, sealed, if the handler changes it. It takes the place of sm.LoadAndSave.
+// The response is buffered until the handler returns, so that changes made to the session
+// after the handler starts writing are kept, and so that, if the session cannot be saved,
+// only sm.ErrorFunc's response is sent.
+func (s *CookieStore) LoadAndSave(sm *scs.SessionManager, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Cookie")
+
+		var value string
+		cookie, err := r.Cookie(sm.Cookie.Name)
+		if err == nil {
+			value = cookie.Value
+		}
+
+		sealed := &sealedCookie{}
+		ctx, err := sm.Load(context.WithValue(r.Context(), sealedCookieKey{}, sealed), value)
+		if err != nil {
+			sm.ErrorFunc(w, r, err)
+			return
+		}
+
+		sr := r.WithContext(ctx)
+
+		bw := &bufferedResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, sr)
+
+		err = s.commit(sm, w, sr, sealed)
+		if err != nil {
+			sm.ErrorFunc(w, r, err)
+			return
+		}
+
+		if bw.code != 0 {
+			w.WriteHeader(bw.code)
+		}
+		_, _ = w.Write(bw.buf.Bytes())
+	})
+}
+
+// commit writes the session into the cookie if it has changed, or was sealed with an earlier
+// key, and removes the cookie if the session was destroyed
+func (s *CookieStore) commit(sm *scs.SessionManager, w http.ResponseWriter, r *http.Request, sealed *sealedCookie) error {
+	ctx := r.Context()
+
+	switch sm.Status(ctx) {
+	case scs.Destroyed:
+		sm.WriteSessionCookie(ctx, w, "", time.Time{})
+		return nil
+	case scs.Unmodified:
+		if !sealed.stale {
+			return nil
+		}
+	}
+
+	_, expiry, err := sm.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	sm.WriteSessionCookie(ctx, w, sealed.value, expiry)
+	return nil
+}
+
+// bufferedResponseWriter holds the response until the session has been written into the
+// cookie, which has to happen before the response's headers are sent
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	buf  bytes.Buffer
+	code int
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return bw.buf.Write(b)
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(code int) {
+	if bw.code == 0 {
+		bw.code = code
+	}
+}
@@ -0,0 +1,88 @@
+package session
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tschenhau/celeritas/sqlbind"
+)
+
+// DatabaseIndex keeps the index in the user_sessions table of a postgres, mysql, mariadb or
+// sqlite database, created with the sessions table by `celeritas make session`, or on its own
+// by `celeritas make user-sessions`. Rows older than TTL are removed when the user's sessions
+// are next read.
+type DatabaseIndex struct {
+	DB     *sql.DB
+	DBType string
+	TTL    time.Duration
+}
+
+// NewDatabaseIndex returns an index in the user_sessions table of db
+func NewDatabaseIndex(db *sql.DB, dbType string, ttl time.Duration) *DatabaseIndex {
+	return &DatabaseIndex{DB: db, DBType: dbType, TTL: ttl}
+}
+
+// rebind converts ? placeholders to $1, $2... for postgres
+func (d *DatabaseIndex) rebind(query string) string {
+	return sqlbind.Rebind(d.DBType, query)
+}
+
+func (d *DatabaseIndex) Save(userID int, token string, e Entry) error {
+	var upsert string
+	switch d.DBType {
+	case "mysql", "mariadb":
+		upsert = `insert into user_sessions (token, user_id, ip_address, user_agent, last_activity) values (?, ?, ?, ?, ?)
+			on duplicate key update user_id = values(user_id), ip_address = values(ip_address),
+			user_agent = values(user_agent), last_activity = values(last_activity)`
+	default:
+		upsert = `insert into user_sessions (token, user_id, ip_address, user_agent, last_activity) values (?, ?, ?, ?, ?)
+			on conflict (token) do update set user_id = excluded.user_id, ip_address = excluded.ip_address,
+			user_agent = excluded.user_agent, last_activity = excluded.last_activity`
+	}
+
+	_, err := d.DB.Exec(d.rebind(upsert), token, userID, e.IP, e.UserAgent, e.LastActivity.Unix())
+	return err
+}
+
+func (d *DatabaseIndex) Entries(userID int) (map[string]Entry, error) {
+	_, err := d.DB.Exec(d.rebind(`delete from user_sessions where user_id = ? and last_activity < ?`),
+		userID, time.Now().Add(-d.TTL).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.DB.Query(d.rebind(`select token, ip_address, user_agent, last_activity
+		from user_sessions where user_id = ?`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[string]Entry)
+	for rows.Next() {
+		var token string
+		var e Entry
+		var lastActivity int64
+
+		err = rows.Scan(&token, &e.IP, &e.UserAgent, &lastActivity)
+		if err != nil {
+			return nil, err
+		}
+
+		e.LastActivity = time.Unix(lastActivity, 0)
+		entries[token] = e
+	}
+
+	return entries, rows.Err()
+}
+
+func (d *DatabaseIndex) Remove(userID int, tokens ...string) error {
+	for _, token := range tokens {
+		_, err := d.DB.Exec(d.rebind(`delete from user_sessions where user_id = ? and token = ?`), userID, token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry is what an Index records about a session each time its user makes a request
+type Entry struct {
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// Index records the sessions of each user, by token, so that they can be listed and revoked.
+// It only records them: a session removed from the store may linger in the index until
+// UserSessions.ListSessions notices it is gone.
+type Index interface {
+	// Save records e as the latest activity of the session token, which belongs to userID
+	Save(userID int, token string, e Entry) error
+	// Entries returns the sessions recorded for userID, by token
+	Entries(userID int) (map[string]Entry, error)
+	// Remove forgets the sessions tokens of userID
+	Remove(userID int, tokens ...string) error
+}
+
+// MemoryIndex keeps the index in this process, for sessions kept in memory
+type MemoryIndex struct {
+	mu    sync.Mutex
+	users map[int]map[string]Entry
+}
+
+// NewMemoryIndex returns an empty index
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{users: make(map[int]map[string]Entry)}
+}
+
+func (m *MemoryIndex) Save(userID int, token string, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.users[userID] == nil {
+		m.users[userID] = make(map[string]Entry)
+	}
+	m.users[userID][token] = e
+
+	return nil
+}
+
+func (m *MemoryIndex) Entries(userID int) (map[string]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make(map[string]Entry, len(m.users[userID]))
+	for token, e := range m.users[userID] {
+		entries[token] = e
+	}
+
+	return entries, nil
+}
+
+func (m *MemoryIndex) Remove(userID int, tokens ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range tokens {
+		delete(m.users[userID], token)
+	}
+	if len(m.users[userID]) == 0 {
+		delete(m.users, userID)
+	}
+
+	return nil
+}
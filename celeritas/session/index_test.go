@@ -0,0 +1,109 @@
+package session
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+// testIndex saves, lists and removes sessions in index
+func testIndex(t *testing.T, index Index) {
+	t.Helper()
+
+	now := time.Now().Truncate(time.Second)
+
+	err := index.Save(7, "abc", Entry{IP: "203.0.113.7", UserAgent: "laptop", LastActivity: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = index.Save(7, "def", Entry{IP: "203.0.113.8", UserAgent: "phone", LastActivity: now})
+	_ = index.Save(8, "ghi", Entry{IP: "203.0.113.9", UserAgent: "someone else", LastActivity: now})
+
+	// saving again updates the entry
+	err = index.Save(7, "abc", Entry{IP: "203.0.113.7", UserAgent: "laptop", LastActivity: now.Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := index.Entries(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries["abc"].UserAgent != "laptop" || !entries["abc"].LastActivity.Equal(now.Add(time.Second)) {
+		t.Errorf("wrong entries for user 7: %+v", entries)
+	}
+
+	err = index.Remove(7, "abc", "ghi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ = index.Entries(7)
+	if len(entries) != 1 || entries["def"].IP != "203.0.113.8" {
+		t.Errorf("expected only def to be left for user 7, got %+v", entries)
+	}
+
+	// removing another user's token does nothing
+	entries, _ = index.Entries(8)
+	if len(entries) != 1 {
+		t.Errorf("expected user 8 to keep their session, got %+v", entries)
+	}
+}
+
+func TestMemoryIndex(t *testing.T) {
+	testIndex(t, NewMemoryIndex())
+}
+
+func TestRedisIndex(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.Addr())
+		},
+	}
+	defer pool.Close()
+
+	testIndex(t, NewRedisIndex(pool, time.Hour))
+
+	if ttl := s.TTL("scs:user:7"); ttl != time.Hour {
+		t.Errorf("expected the index to expire after an hour, got %s", ttl)
+	}
+}
+
+func TestDatabaseIndex(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migration, err := os.ReadFile("../cli/templates/migrations/sqlite_user_sessions.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(string(migration))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := NewDatabaseIndex(db, "sqlite", time.Hour)
+	testIndex(t, index)
+
+	// sessions unused for longer than the ttl are dropped
+	_ = index.Save(9, "old", Entry{LastActivity: time.Now().Add(-2 * time.Hour)})
+
+	entries, err := index.Entries(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected an old session to be dropped, got %+v", entries)
+	}
+}
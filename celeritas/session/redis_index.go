@@ -0,0 +1,77 @@
+package session
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisIndex keeps the index in redis, in a hash per user under Prefix and the user's ID.
+// Each hash expires TTL after the last request made with one of the user's sessions.
+type RedisIndex struct {
+	Pool   *redis.Pool
+	Prefix string
+	TTL    time.Duration
+}
+
+// NewRedisIndex returns an index in the redis behind pool, under the prefix scs:user:, next
+// to the sessions of the scs redis store
+func NewRedisIndex(pool *redis.Pool, ttl time.Duration) *RedisIndex {
+	return &RedisIndex{Pool: pool, Prefix: "scs:user:", TTL: ttl}
+}
+
+func (r *RedisIndex) key(userID int) string {
+	return r.Prefix + strconv.Itoa(userID)
+}
+
+func (r *RedisIndex) Save(userID int, token string, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	conn := r.Pool.Get()
+	defer conn.Close()
+
+	_ = conn.Send("MULTI")
+	_ = conn.Send("HSET", r.key(userID), token, b)
+	_ = conn.Send("PEXPIRE", r.key(userID), r.TTL.Milliseconds())
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (r *RedisIndex) Entries(userID int) (map[string]Entry, error) {
+	conn := r.Pool.Get()
+	defer conn.Close()
+
+	values, err := redis.StringMap(conn.Do("HGETALL", r.key(userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]Entry, len(values))
+	for token, value := range values {
+		var e Entry
+		err = json.Unmarshal([]byte(value), &e)
+		if err != nil {
+			return nil, err
+		}
+		entries[token] = e
+	}
+
+	return entries, nil
+}
+
+func (r *RedisIndex) Remove(userID int, tokens ...string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	conn := r.Pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("HDEL", redis.Args{}.Add(r.key(userID)).AddFlat(tokens)...)
+	return err
+}
@@ -2,6 +2,8 @@ package session
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,8 +12,11 @@ import (
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/redisstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
+	"github.com/dgraph-io/badger/v3"
 	"github.com/gomodule/redigo/redis"
+	"github.com/tschenhau/celeritas/cache"
 )
 
 type Session struct {
@@ -23,9 +28,20 @@ type Session struct {
 	CookieSecure   string
 	DBPool         *sql.DB
 	RedisPool      *redis.Pool
+	BadgerConn     *badger.DB
+	Cache          cache.Cache
+
+	// EncryptionKeys seal cookie sessions: the first seals them, and the rest, which it
+	// replaced, still open the sessions sealed with them
+	EncryptionKeys []string
 }
 
-func (c *Session) InitSession() *scs.SessionManager {
+// InitSession returns a session manager keeping sessions in the store named by SessionType:
+// cookie, which keeps them in the session cookie, sealed with EncryptionKeys; redis, mysql,
+// postgres, sqlite, badger, cache, or memory, which keeps them in this process and loses them
+// on restart. It returns an error if the type is unknown, or if the connection or keys the
+// store needs are missing.
+func (c *Session) InitSession() (*scs.SessionManager, error) {
 	var persist, secure bool
 
 	// how long should sessions last?
@@ -54,16 +70,67 @@ func (c *Session) InitSession() *scs.SessionManager {
 	session.Cookie.SameSite = http.SameSiteLaxMode
 
 	// which session store?
-	switch strings.ToLower(c.SessionType) {
+	sessionType := strings.ToLower(c.SessionType)
+	switch sessionType {
 	case "redis":
+		if c.RedisPool == nil {
+			return nil, errors.New("session: redis sessions need a redis pool")
+		}
 		session.Store = redisstore.New(c.RedisPool)
-	case "mysql", "mariadb":
-		session.Store = mysqlstore.New(c.DBPool)
-	case "postgres", "postgresql":
-		session.Store = postgresstore.New(c.DBPool)
+	case "mysql", "mariadb", "postgres", "postgresql", "sqlite", "sqlite3":
+		if c.DBPool == nil {
+			return nil, fmt.Errorf("session: %s sessions need a database connection", sessionType)
+		}
+		switch sessionType {
+		case "mysql", "mariadb":
+			session.Store = mysqlstore.New(c.DBPool)
+		case "postgres", "postgresql":
+			session.Store = postgresstore.New(c.DBPool)
+		default:
+			session.Store = sqlite3store.New(c.DBPool)
+		}
+	case "badger":
+		if c.BadgerConn == nil {
+			return nil, errors.New("session: badger sessions need a badger database")
+		}
+		session.Store = NewBadgerStore(c.BadgerConn)
+	case "cache":
+		if c.Cache == nil {
+			return nil, errors.New("session: cache sessions need a cache")
+		}
+		session.Store = NewCacheStore(c.Cache)
+	case "cookie":
+		store, err := NewCookieStore(session.Cookie.Name, c.EncryptionKeys...)
+		if err != nil {
+			return nil, err
+		}
+		session.Store = store
+	case "memory", "":
+		// scs keeps sessions in memory unless it is given another store
+	default:
+		return nil, fmt.Errorf("session: unknown session type %q", c.SessionType)
+	}
+
+	return session, nil
+}
+
+// InitUserSessions returns what lists and revokes the sessions of each user in sm, for the
+// stores that can index them: redis, mysql, postgres, sqlite and memory. It returns nil for
+// the others; cookie sessions, for one, are not kept on the server at all.
+func (c *Session) InitUserSessions(sm *scs.SessionManager) *UserSessions {
+	var index Index
+
+	sessionType := strings.ToLower(c.SessionType)
+	switch sessionType {
+	case "redis":
+		index = NewRedisIndex(c.RedisPool, sm.Lifetime)
+	case "mysql", "mariadb", "postgres", "postgresql", "sqlite", "sqlite3":
+		index = NewDatabaseIndex(c.DBPool, sessionType, sm.Lifetime)
+	case "memory", "":
+		index = NewMemoryIndex()
 	default:
-		// cookie
+		return nil
 	}
 
-	return session
+	return NewUserSessions(sm, index)
 }
@@ -1,11 +1,17 @@
 package session
 
 import (
+	"database/sql"
 	"fmt"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestSession_InitSession(t *testing.T) {
@@ -16,11 +22,15 @@ func TestSession_InitSession(t *testing.T) {
 		CookieName:     "celeritas",
 		CookieDomain:   "localhost",
 		SessionType:    "cookie",
+		EncryptionKeys: []string{strings.Repeat("k", 32)},
 	}
 
 	var sm *scs.SessionManager
 
-	ses := c.InitSession()
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	var sessKind reflect.Kind
 	var sessType reflect.Type
@@ -47,3 +57,66 @@ func TestSession_InitSession(t *testing.T) {
 		t.Error("wrong type returned testing cookie session. Expected", reflect.ValueOf(sm).Type(), "and got", sessType)
 	}
 }
+
+func TestSession_InitSession_SQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE sessions (token TEXT PRIMARY KEY, data BLOB NOT NULL, expiry REAL NOT NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "celeritas",
+		SessionType:    "sqlite",
+		DBPool:         db,
+	}
+
+	ses, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ses.Store.(*sqlite3store.SQLite3Store); !ok {
+		t.Fatalf("wrong store returned for sqlite session; got %T", ses.Store)
+	}
+
+	err = ses.Store.Commit("abc", []byte("some data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := ses.Store.Find("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !found || string(b) != "some data" {
+		t.Error("session data not found in sqlite store")
+	}
+}
+
+func TestSession_InitSession_UnknownType(t *testing.T) {
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "celeritas",
+		SessionType:    "mongo",
+	}
+
+	_, err := c.InitSession()
+	if err == nil || !strings.Contains(err.Error(), "mongo") {
+		t.Errorf("expected an error for an unknown session type, got %v", err)
+	}
+
+	// a store without its connection is refused too
+	c.SessionType = "badger"
+	_, err = c.InitSession()
+	if err == nil {
+		t.Error("expected an error for badger sessions without a badger database")
+	}
+}
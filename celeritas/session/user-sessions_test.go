@@ -0,0 +1,182 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newUserSessions returns user sessions kept in memory, and a handler that logs in the user
+// in the user query parameter, logs out with logout, and revokes sessions with revoke or
+// revoke-others
+func newUserSessions(t *testing.T) (*UserSessions, http.Handler) {
+	t.Helper()
+
+	c := &Session{
+		CookieLifetime: "100",
+		CookieName:     "celeritas",
+		SessionType:    "memory",
+	}
+
+	sm, err := c.InitSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := c.InitUserSessions(sm)
+	if u == nil {
+		t.Fatal("expected user sessions for the memory store")
+	}
+
+	var handlerErr error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Has("user"):
+			userID, _ := strconv.Atoi(q.Get("user"))
+			_ = sm.RenewToken(r.Context())
+			sm.Put(r.Context(), "userID", userID)
+		case q.Has("logout"):
+			_ = sm.Destroy(r.Context())
+		case q.Has("revoke"):
+			handlerErr = u.RevokeSession(r.Context(), q.Get("revoke"))
+		case q.Has("revoke-others"):
+			handlerErr = u.RevokeOtherSessions(r.Context())
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Cleanup(func() {
+		if handlerErr != nil {
+			t.Error(handlerErr)
+		}
+	})
+
+	return u, sm.LoadAndSave(u.Track(handler))
+}
+
+// login logs user in from a device with userAgent, and returns its session cookie
+func login(t *testing.T, h http.Handler, user, userAgent string) *http.Cookie {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/?user="+user, nil)
+	req.Header.Set("User-Agent", userAgent)
+	req.RemoteAddr = "203.0.113.7:4321"
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	return sessionCookie(t, rr.Result())
+}
+
+func request(h http.Handler, target string, cookie *http.Cookie) {
+	req := httptest.NewRequest("GET", target, nil)
+	req.AddCookie(cookie)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// listSessions lists the sessions of userID from the request made with cookie
+func listSessions(t *testing.T, u *UserSessions, userID int, cookie *http.Cookie) []Activity {
+	t.Helper()
+
+	ctx, err := u.Manager.Load(httptest.NewRequest("GET", "/", nil).Context(), cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := u.ListSessions(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sessions
+}
+
+func TestUserSessions_ListSessions(t *testing.T) {
+	u, h := newUserSessions(t)
+
+	laptop := login(t, h, "7", "laptop")
+	phone := login(t, h, "7", "phone")
+	login(t, h, "8", "someone else")
+
+	sessions := listSessions(t, u, 7, phone)
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+
+	// most recently used first
+	if sessions[0].UserAgent != "phone" || !sessions[0].Current || sessions[1].Current {
+		t.Errorf("expected the phone's session first, and current, got %+v", sessions)
+	}
+	if sessions[1].IP != "203.0.113.7" || sessions[1].ID == "" || sessions[1].ID == laptop.Value {
+		t.Errorf("wrong session recorded: %+v", sessions[1])
+	}
+
+	// logging out forgets the session
+	request(h, "/?logout", laptop)
+	if sessions := listSessions(t, u, 7, phone); len(sessions) != 1 {
+		t.Errorf("expected the logged out session to be forgotten, got %+v", sessions)
+	}
+}
+
+func TestUserSessions_RevokeSession(t *testing.T) {
+	u, h := newUserSessions(t)
+
+	laptop := login(t, h, "7", "laptop")
+	phone := login(t, h, "7", "phone")
+	other := login(t, h, "8", "someone else")
+
+	var laptopID string
+	for _, s := range listSessions(t, u, 7, phone) {
+		if !s.Current {
+			laptopID = s.ID
+		}
+	}
+
+	// another user cannot revoke the session
+	ctx, _ := u.Manager.Load(httptest.NewRequest("GET", "/", nil).Context(), other.Value)
+	err := u.RevokeSession(ctx, laptopID)
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound revoking another user's session, got %v", err)
+	}
+
+	request(h, "/?revoke="+laptopID, phone)
+
+	_, found, _ := u.Manager.Store.Find(laptop.Value)
+	if found {
+		t.Error("expected the revoked session to be deleted from the store")
+	}
+
+	sessions := listSessions(t, u, 7, phone)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("expected only the current session to be left, got %+v", sessions)
+	}
+}
+
+func TestUserSessions_RevokeOtherSessions(t *testing.T) {
+	u, h := newUserSessions(t)
+
+	login(t, h, "7", "laptop")
+	login(t, h, "7", "tablet")
+	phone := login(t, h, "7", "phone")
+	other := login(t, h, "8", "someone else")
+
+	request(h, "/?revoke-others", phone)
+
+	sessions := listSessions(t, u, 7, phone)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("expected only the phone's session to be left, got %+v", sessions)
+	}
+
+	if sessions := listSessions(t, u, 8, other); len(sessions) != 1 {
+		t.Errorf("expected another user's session to be left alone, got %+v", sessions)
+	}
+
+	// without a logged in user there is nothing to revoke
+	ctx, _ := u.Manager.Load(httptest.NewRequest("GET", "/", nil).Context(), "")
+	if err := u.RevokeOtherSessions(ctx); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("expected ErrNotLoggedIn, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// ErrSessionNotFound is returned when the logged in user has no session with the given ID
+var ErrSessionNotFound = errors.New("session: the user has no such session")
+
+// ErrNotLoggedIn is returned when sessions are revoked for a request without a logged in user
+var ErrNotLoggedIn = errors.New("session: no user is logged in")
+
+// maxUserAgent is the longest user agent recorded, so that it fits the user_sessions table
+const maxUserAgent = 255
+
+// Activity describes one of a user's sessions
+type Activity struct {
+	ID           string // identifies the session without revealing its token
+	IP           string
+	UserAgent    string
+	LastActivity time.Time
+	Current      bool // whether this is the session of the request that listed it
+}
+
+// UserSessions lists and revokes the sessions of each user, which Track records in Index as
+// they are used. A session belongs to the user whose ID is stored in it under UserKey.
+type UserSessions struct {
+	Manager *scs.SessionManager
+	Index   Index
+	UserKey string
+	Logger  *slog.Logger
+}
+
+// NewUserSessions returns the user sessions of sm, recorded in index, for users whose ID is
+// stored under userID, as the handlers created by `celeritas make auth` do
+func NewUserSessions(sm *scs.SessionManager, index Index) *UserSessions {
+	return &UserSessions{Manager: sm, Index: index, UserKey: "userID", Logger: slog.Default()}
+}
+
+// sessionID returns the ID shown for the session token
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Track records the session of each request made by a logged in user, with the client's IP
+// address and user agent, once the request has been handled. It must run inside the session
+// manager's LoadAndSave. A session that is logged out of, or replaced by RenewToken, is
+// forgotten. Errors are logged, rather than failing the request.
+func (u *UserSessions) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		token, userID := u.Manager.Token(ctx), u.Manager.GetInt(ctx, u.UserKey)
+
+		next.ServeHTTP(w, r)
+
+		// a new session has no token until it is committed, which may not have happened yet
+		newToken, newUserID := u.Manager.Token(ctx), u.Manager.GetInt(ctx, u.UserKey)
+
+		if token != "" && userID != 0 && (token != newToken || userID != newUserID) {
+			u.logError("removing a session from the index", u.Index.Remove(userID, token))
+		}
+
+		if newToken != "" && newUserID != 0 {
+			userAgent := r.UserAgent()
+			if len(userAgent) > maxUserAgent {
+				userAgent = userAgent[:maxUserAgent]
+			}
+
+			u.logError("saving a session in the index", u.Index.Save(newUserID, newToken, Entry{
+				IP:           clientIP(r),
+				UserAgent:    userAgent,
+				LastActivity: time.Now(),
+			}))
+		}
+	})
+}
+
+func (u *UserSessions) logError(msg string, err error) {
+	if err != nil {
+		u.Logger.Error(msg, "error", err)
+	}
+}
+
+// clientIP returns the address of the client, which chi's RealIP middleware takes from the
+// proxy headers
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ListSessions returns the sessions of userID, most recently used first. Current is set for
+// the session of ctx. Sessions that have expired, or been removed from the store, are
+// dropped from the index.
+func (u *UserSessions) ListSessions(ctx context.Context, userID int) ([]Activity, error) {
+	entries, err := u.live(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := u.Manager.Token(ctx)
+
+	sessions := make([]Activity, 0, len(entries))
+	for token, e := range entries {
+		sessions = append(sessions, Activity{
+			ID:           sessionID(token),
+			IP:           e.IP,
+			UserAgent:    e.UserAgent,
+			LastActivity: e.LastActivity,
+			Current:      token == current,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActivity.After(sessions[j].LastActivity)
+	})
+
+	return sessions, nil
+}
+
+// live returns the entries of userID whose sessions are still in the store, by token
+func (u *UserSessions) live(userID int) (map[string]Entry, error) {
+	entries, err := u.Index.Entries(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var gone []string
+	for token := range entries {
+		_, found, err := u.Manager.Store.Find(token)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			gone = append(gone, token)
+			delete(entries, token)
+		}
+	}
+
+	if len(gone) > 0 {
+		err = u.Index.Remove(userID, gone...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// token returns the token of the session id of the user logged in to ctx
+func (u *UserSessions) token(ctx context.Context, id string) (int, string, error) {
+	userID := u.Manager.GetInt(ctx, u.UserKey)
+	if userID == 0 {
+		return 0, "", ErrNotLoggedIn
+	}
+
+	entries, err := u.live(userID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	for token := range entries {
+		if sessionID(token) == id {
+			return userID, token, nil
+		}
+	}
+
+	return 0, "", ErrSessionNotFound
+}
+
+// SessionValues returns the data of the session id of the user logged in to ctx, so that
+// what it refers to, such as a remember me token, can be revoked along with it
+func (u *UserSessions) SessionValues(ctx context.Context, id string) (map[string]interface{}, error) {
+	_, token, err := u.token(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	b, found, err := u.Manager.Store.Find(token)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrSessionNotFound
+	}
+
+	_, values, err := u.Manager.Codec.Decode(b)
+	return values, err
+}
+
+// RevokeSession ends the session id of the user logged in to ctx, which only that user can
+// do. Revoking the session of ctx itself destroys it, logging the user out.
+func (u *UserSessions) RevokeSession(ctx context.Context, id string) error {
+	userID, token, err := u.token(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if token == u.Manager.Token(ctx) {
+		return u.Manager.Destroy(ctx)
+	}
+
+	return u.revoke(userID, token)
+}
+
+// RevokeOtherSessions ends every session of the user logged in to ctx but the session of ctx
+func (u *UserSessions) RevokeOtherSessions(ctx context.Context) error {
+	userID := u.Manager.GetInt(ctx, u.UserKey)
+	if userID == 0 {
+		return ErrNotLoggedIn
+	}
+
+	entries, err := u.live(userID)
+	if err != nil {
+		return err
+	}
+
+	current := u.Manager.Token(ctx)
+	for token := range entries {
+		if token == current {
+			continue
+		}
+
+		err = u.revoke(userID, token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *UserSessions) revoke(userID int, token string) error {
+	err := u.Manager.Store.Delete(token)
+	if err != nil {
+		return err
+	}
+
+	return u.Index.Remove(userID, token)
+}
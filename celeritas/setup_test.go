@@ -0,0 +1,65 @@
+package celeritas
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+)
+
+// mysqlAddr is the address of an in-process, in-memory mysql compatible server, which
+// stands in for a real mysql/mariadb database so that tests don't need docker
+var mysqlAddr string
+
+func TestMain(m *testing.M) {
+	db := memory.NewDatabase("celeritas")
+	db.BaseDatabase.EnablePrimaryKeyIndexes()
+	pro := memory.NewDBProvider(db)
+	engine := sqle.NewDefault(pro)
+
+	cfg := server.Config{
+		Protocol: "tcp",
+		Address:  "127.0.0.1:0",
+	}
+
+	s, err := server.NewServer(cfg, engine, memory.NewSessionBuilder(pro), nil)
+	if err != nil {
+		log.Fatal("could not create mysql server: ", err)
+	}
+	mysqlAddr = s.Listener.Addr().String()
+
+	go func() {
+		_ = s.Start()
+	}()
+
+	code := m.Run()
+
+	_ = s.Close()
+	os.Exit(code)
+}
+
+// testMySQLConfig returns a config with the database pointed at the test mysql server
+func testMySQLConfig(t *testing.T) Config {
+	host, port, err := net.SplitHostPort(mysqlAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Database.Type = "mysql"
+	cfg.Database.Host = host
+	cfg.Database.Port = p
+	cfg.Database.User = "root"
+	cfg.Database.Name = "celeritas"
+	return cfg
+}
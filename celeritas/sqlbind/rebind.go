@@ -0,0 +1,30 @@
+// Package sqlbind adapts queries written with ? placeholders to the database they run on, so
+// that the database cache, queue and session index can share one set of queries.
+package sqlbind
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rebind converts the ? placeholders in query to $1, $2... when dbType is postgres, and
+// returns query unchanged for mysql, mariadb and sqlite
+func Rebind(dbType, query string) string {
+	switch dbType {
+	case "postgres", "postgresql", "pgx":
+	default:
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
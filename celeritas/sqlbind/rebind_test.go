@@ -0,0 +1,23 @@
+package sqlbind
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	query := `select value from cache where cache_key = ? and expires_at > ?`
+
+	tests := []struct {
+		dbType string
+		want   string
+	}{
+		{"postgres", `select value from cache where cache_key = $1 and expires_at > $2`},
+		{"pgx", `select value from cache where cache_key = $1 and expires_at > $2`},
+		{"mysql", query},
+		{"sqlite", query},
+	}
+
+	for _, tt := range tests {
+		if got := Rebind(tt.dbType, query); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.dbType, tt.want, got)
+		}
+	}
+}
@@ -10,27 +10,7 @@ type initPaths struct {
 	folderNames []string
 }
 
-// cookieConfig holds cookie config values
-type cookieConfig struct {
-	name     string
-	lifetime string
-	persist  string
-	secure   string
-	domain   string
-}
-
-type databaseConfig struct {
-	dsn      string
-	database string
-}
-
 type Database struct {
 	DataType string
 	Pool     *sql.DB
 }
-
-type redisConfig struct {
-	host     string
-	password string
-	prefix   string
-}
@@ -1,4 +1,4 @@
-// go:build integration
+//go:build integration
 
 // run tests with this command: go test . --tags integration --count=1
 
@@ -8,10 +8,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"testing"
-	"time"
 
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4"
@@ -29,16 +27,6 @@ var (
 	dsn      = "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC connect_timeout=5"
 )
 
-var dummyUser = User{
-	FirstName: "Some",
-	LastName:  "Guy",
-	Email:     "me@here.com",
-	Active:    1,
-	Password:  "password",
-}
-
-var models Models
-var testDB *sql.DB
 var resource *dockertest.Resource
 var pool *dockertest.Pool
 
@@ -173,400 +161,3 @@ EXECUTE PROCEDURE trigger_set_timestamp();
 	}
 	return nil
 }
-
-func TestUser_Table(t *testing.T) {
-	s := models.Users.Table()
-	if s != "users" {
-		t.Error("wrong table name returned: ", s)
-	}
-}
-
-func TestUser_Insert(t *testing.T) {
-	id, err := models.Users.Insert(dummyUser)
-	if err != nil {
-		t.Error("failed to insert user: ", err)
-	}
-
-	if id == 0 {
-		t.Error("0 returned as id after insert")
-	}
-}
-
-func TestUser_Get(t *testing.T) {
-	u, err := models.Users.Get(1)
-	if err != nil {
-		t.Error("failed to get user: ", err)
-	}
-
-	if u.ID == 0 {
-		t.Error("id of returned user is 0: ", err)
-	}
-}
-
-func TestUser_GetAll(t *testing.T) {
-	_, err := models.Users.GetAll()
-	if err != nil {
-		t.Error("failed to get user: ", err)
-	}
-}
-
-func TestUser_GetByEmail(t *testing.T) {
-	u, err := models.Users.GetByEmail("me@here.com")
-	if err != nil {
-		t.Error("failed to get user: ", err)
-	}
-
-	if u.ID == 0 {
-		t.Error("id of returned user is 0: ", err)
-	}
-}
-
-func TestUser_Update(t *testing.T) {
-	u, err := models.Users.Get(1)
-	if err != nil {
-		t.Error("failed to get user: ", err)
-	}
-
-	u.LastName = "Smith"
-	err = u.Update(*u)
-	if err != nil {
-		t.Error("failed to update user: ", err)
-	}
-
-	u, err = models.Users.Get(1)
-	if err != nil {
-		t.Error("failed to get user: ", err)
-	}
-
-	if u.LastName != "Smith" {
-		t.Error("last name not updated in database")
-	}
-}
-
-func TestUser_PasswordMatches(t *testing.T) {
-	u, err := models.Users.Get(1)
-	if err != nil {
-		t.Error("failed to get user: ", err)
-	}
-
-	matches, err := u.PasswordMatches("password")
-	if err != nil {
-		t.Error("error checking match: ", err)
-	}
-
-	if !matches {
-		t.Error("password does match when it should")
-	}
-
-	matches, err = u.PasswordMatches("123")
-	if err != nil {
-		t.Error("error checking match: ", err)
-	}
-
-	if matches {
-		t.Error("password matches when it should not")
-	}
-}
-
-func TestUser_ResetPassword(t *testing.T) {
-	err := models.Users.ResetPassword(1, "new_password")
-	if err != nil {
-		t.Error("error resetting password: ", err)
-	}
-
-	err = models.Users.ResetPassword(2, "new_password")
-	if err == nil {
-		t.Error("did not get an error when trying to reset password for non-existent user")
-	}
-}
-
-func TestUser_Delete(t *testing.T) {
-	err := models.Users.Delete(1)
-	if err != nil {
-		t.Error("failed to delete user: ", err)
-	}
-
-	_, err = models.Users.Get(1)
-	if err == nil {
-		t.Error("retrieved user who was supposed to be deleted")
-	}
-}
-
-func TestToken_Table(t *testing.T) {
-	s := models.Tokens.Table()
-	if s != "tokens" {
-		t.Error("wrong table name returned for tokens")
-	}
-}
-
-func TestToken_GenerateToken(t *testing.T) {
-	id, err := models.Users.Insert(dummyUser)
-	if err != nil {
-		t.Error("error inserting user: ", err)
-	}
-
-	_, err = models.Tokens.GenerateToken(id, time.Hour*24*365)
-	if err != nil {
-		t.Error("error generating token: ", err)
-	}
-}
-
-func TestToken_Insert(t *testing.T) {
-	u, err := models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error("failed to get user")
-	}
-
-	token, err := models.Tokens.GenerateToken(u.ID, time.Hour*24*365)
-	if err != nil {
-		t.Error("error generating token: ", err)
-	}
-
-	err = models.Tokens.Insert(*token, *u)
-	if err != nil {
-		t.Error("error insering token: ", err)
-	}
-}
-
-func TestToken_GetUserForToken(t *testing.T) {
-	token := "abc"
-	_, err := models.Tokens.GetUserForToken(token)
-	if err == nil {
-		t.Error("error expected but not recieved when getting user with a bad token")
-	}
-
-	u, err := models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error("failed to get user")
-	}
-
-	_, err = models.Tokens.GetUserForToken(u.Token.PlainText)
-	if err != nil {
-		t.Error("failed to get user with valid token: ", err)
-	}
-}
-
-func TestToken_GetTokensForUser(t *testing.T) {
-	tokens, err := models.Tokens.GetTokensForUser(1)
-	if err != nil {
-		t.Error(err)
-	}
-
-	if len(tokens) > 0 {
-		t.Error("tokens returned for non-existent user")
-	}
-}
-
-func TestToken_Get(t *testing.T) {
-	u, err := models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error("failed to get user")
-	}
-
-	_, err = models.Tokens.Get(u.Token.ID)
-	if err != nil {
-		t.Error("error getting token by id: ", err)
-	}
-}
-
-func TestToken_GetByToken(t *testing.T) {
-	u, err := models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error("failed to get user")
-	}
-
-	_, err = models.Tokens.GetByToken(u.Token.PlainText)
-	if err != nil {
-		t.Error("error getting token by token: ", err)
-	}
-
-	_, err = models.Tokens.GetByToken("123")
-	if err == nil {
-		t.Error("no error getting non-existing token by token: ", err)
-	}
-}
-
-var authData = []struct {
-	name          string
-	token         string
-	email         string
-	errorExpected bool
-	message       string
-}{
-	{"invalid", "abcdefghijklmnopqrstuvwxyz", "a@here.com", true, "invalid token accepted as valid"},
-	{"invalid_length", "abcdefghijklmnopqrstuvwxy", "a@here.com", true, "token of wrong length token accepted as valid"},
-	{"no_user", "abcdefghijklmnopqrstuvwxyz", "a@here.com", true, "no user, but token accepted as valid"},
-	{"valid", "", "me@here.com", false, "valid token reported as invalid"},
-}
-
-func TestToken_AuthenticateToken(t *testing.T) {
-	for _, tt := range authData {
-		token := ""
-		if tt.email == dummyUser.Email {
-			user, err := models.Users.GetByEmail(tt.email)
-			if err != nil {
-				t.Error("failed to get user: ", err)
-			}
-			token = user.Token.PlainText
-		} else {
-			token = tt.token
-		}
-
-		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Add("Authorization", "Bearer "+token)
-
-		_, err := models.Tokens.AuthenticateToken(req)
-		if tt.errorExpected && err == nil {
-			t.Errorf("%s: %s", tt.name, tt.message)
-		} else if !tt.errorExpected && err != nil {
-			t.Errorf("%s: %s - %s", tt.name, tt.message, err)
-		} else {
-			t.Logf("passed %s", tt.name)
-		}
-	}
-}
-
-func TestToken_Delete(t *testing.T) {
-	u, err := models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = models.Tokens.DeleteByToken(u.Token.PlainText)
-	if err != nil {
-		t.Error("error deleting token: ", err)
-	}
-}
-
-func TestToken_ExpiredToken(t *testing.T) {
-	// insert a token
-	u, err := models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error(err)
-	}
-
-	token, err := models.Tokens.GenerateToken(u.ID, -time.Hour)
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = models.Tokens.Insert(*token, *u)
-	if err != nil {
-		t.Error(err)
-	}
-
-	req, _ := http.NewRequest("GET", "/", nil)
-	req.Header.Add("Authorization", "Bearer "+token.PlainText)
-
-	_, err = models.Tokens.AuthenticateToken(req)
-	if err == nil {
-		t.Error("failed to catch expired token")
-	}
-
-}
-
-func TestToken_BadHeader(t *testing.T) {
-	req, _ := http.NewRequest("GET", "/", nil)
-	_, err := models.Tokens.AuthenticateToken(req)
-	if err == nil {
-		t.Error("failed to catch missing auth header")
-	}
-
-	req, _ = http.NewRequest("GET", "/", nil)
-	req.Header.Add("Authorization", "abc")
-	_, err = models.Tokens.AuthenticateToken(req)
-	if err == nil {
-		t.Error("failed to catch bad auth header")
-	}
-
-	newUser := User{
-		FirstName: "temp",
-		LastName:  "temp_last",
-		Email:     "you@there.com",
-		Active:    1,
-		Password:  "abc",
-	}
-
-	id, err := models.Users.Insert(newUser)
-	if err != nil {
-		t.Error(err)
-	}
-
-	token, err := models.Tokens.GenerateToken(id, 1*time.Hour)
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = models.Tokens.Insert(*token, newUser)
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = models.Users.Delete(id)
-	if err != nil {
-		t.Error(err)
-	}
-
-	req, _ = http.NewRequest("GET", "/", nil)
-	req.Header.Add("Authorization", "Bearer "+token.PlainText)
-	_, err = models.Tokens.AuthenticateToken(req)
-	if err == nil {
-		t.Error("failed to catch token for deleted user")
-	}
-
-}
-
-func TestToken_DeleteNonExistentToken(t *testing.T) {
-	err := models.Tokens.DeleteByToken("abc")
-	if err != nil {
-		t.Error("error deleting token")
-	}
-}
-
-func TestToken_ValidToken(t *testing.T) {
-	u, err := models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error(err)
-	}
-
-	newToken, err := models.Tokens.GenerateToken(u.ID, 24*time.Hour)
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = models.Tokens.Insert(*newToken, *u)
-	if err != nil {
-		t.Error(err)
-	}
-
-	okay, err := models.Tokens.ValidToken(newToken.PlainText)
-	if err != nil {
-		t.Error("error calling ValidToken: ", err)
-	}
-	if !okay {
-		t.Error("valid token reported as invalid")
-	}
-
-	okay, _ = models.Tokens.ValidToken("abc")
-	if okay {
-		t.Error("invalid token reported as valid")
-	}
-
-	u, err = models.Users.GetByEmail(dummyUser.Email)
-	if err != nil {
-		t.Error(err)
-	}
-
-	err = models.Tokens.Delete(u.Token.ID)
-	if err != nil {
-		t.Error(err)
-	}
-
-	okay, err = models.Tokens.ValidToken(u.Token.PlainText)
-	if err == nil {
-		t.Error(err)
-	}
-	if okay {
-		t.Error("no error reported when validating non-existent token")
-	}
-}
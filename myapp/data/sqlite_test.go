@@ -0,0 +1,84 @@
+//go:build !integration && !unit
+
+package data
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TestMain runs the model tests against a throwaway sqlite database, so that they
+// need no external services. Use --tags integration to run them against postgres.
+func TestMain(m *testing.M) {
+	os.Setenv("DATABASE_TYPE", "sqlite")
+	os.Setenv("UPPER_DB_LOG", "ERROR")
+
+	dir, err := os.MkdirTemp("", "celeritas-data")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testDB, err = sql.Open("sqlite3", filepath.Join(dir, "test.db")+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = createSQLiteTables(testDB)
+	if err != nil {
+		log.Fatalf("error creating tables: %s", err)
+	}
+
+	models = New(testDB)
+
+	code := m.Run()
+
+	_ = testDB.Close()
+	_ = os.RemoveAll(dir)
+
+	os.Exit(code)
+}
+
+// authMigration is the sqlite migration `celeritas make auth` writes, so that the tests run
+// against the schema that applications get
+const authMigration = "../../celeritas/cli/templates/migrations/auth_tables.sqlite.sql"
+
+func createSQLiteTables(db *sql.DB) error {
+	stmt, err := os.ReadFile(authMigration)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(string(stmt))
+	return err
+}
+
+func TestSQLite_UpdatedAtTrigger(t *testing.T) {
+	res, err := testDB.Exec(`insert into users (first_name, last_name, email, password, updated_at)
+		values ('Trigger', 'Test', 'trigger@example.com', 'x', '2000-01-01 00:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	defer testDB.Exec(`delete from users where id = ?`, id)
+
+	// an update that leaves updated_at alone has it set by the trigger
+	_, err = testDB.Exec(`update users set first_name = 'Triggered' where id = ?`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updatedAt string
+	err = testDB.QueryRow(`select updated_at from users where id = ?`, id).Scan(&updatedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(updatedAt, "2000") {
+		t.Errorf("expected the trigger to set updated_at, got %s", updatedAt)
+	}
+}
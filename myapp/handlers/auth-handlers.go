@@ -3,6 +3,7 @@ package handlers
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"myapp/data"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/CloudyKit/jet/v6"
 	"github.com/tschenhau/celeritas/mailer"
+	"github.com/tschenhau/celeritas/session"
 	"github.com/tschenhau/celeritas/urlsigner"
 )
 
@@ -175,8 +177,7 @@ func (h *Handlers) PostForgot(w http.ResponseWriter, r *http.Request) {
 		From:     "admin@example.com",
 	}
 
-	h.App.Mail.Jobs <- msg
-	res := <-h.App.Mail.Results
+	res := <-h.App.Mail.SendAsync(r.Context(), msg)
 	if res.Error != nil {
 		h.App.ErrorStatus(w, http.StatusBadRequest)
 		return
@@ -259,3 +260,118 @@ func (h *Handlers) PostResetPassword(w http.ResponseWriter, r *http.Request) {
 	h.App.Session.Put(r.Context(), "flash", "Password reset. You can now log in.")
 	http.Redirect(w, r, "/users/login", http.StatusSeeOther)
 }
+
+// Sessions lists the devices the user is logged in on, so that they can be logged out
+func (h *Handlers) Sessions(w http.ResponseWriter, r *http.Request) {
+	userID := h.App.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return
+	}
+
+	// cookie sessions are not kept on the server, so they cannot be listed
+	if h.App.UserSessions == nil {
+		h.App.ErrorStatus(w, http.StatusNotFound)
+		return
+	}
+
+	sessions, err := h.App.UserSessions.ListSessions(r.Context(), userID)
+	if err != nil {
+		h.App.ErrorLog.Println(err)
+		h.App.Error500(w, r)
+		return
+	}
+
+	vars := make(jet.VarMap)
+	vars.Set("sessions", sessions)
+
+	err = h.render(w, r, "sessions", vars, nil)
+	if err != nil {
+		h.App.ErrorLog.Println("error rendering:", err)
+	}
+}
+
+// PostRevokeSession logs the user out of one of their other devices
+func (h *Handlers) PostRevokeSession(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		h.App.ErrorStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	if h.App.UserSessions == nil {
+		h.App.ErrorStatus(w, http.StatusNotFound)
+		return
+	}
+
+	id := r.Form.Get("id")
+	h.deleteSessionRememberToken(r, id)
+
+	err = h.App.UserSessions.RevokeSession(r.Context(), id)
+	switch {
+	case errors.Is(err, session.ErrNotLoggedIn):
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return
+	case errors.Is(err, session.ErrSessionNotFound):
+		h.App.Session.Put(r.Context(), "error", "That device has already been logged out")
+	case err != nil:
+		h.App.ErrorLog.Println(err)
+		h.App.Error500(w, r)
+		return
+	default:
+		h.App.Session.Put(r.Context(), "flash", "The device has been logged out")
+	}
+
+	http.Redirect(w, r, "/users/sessions", http.StatusSeeOther)
+}
+
+// PostRevokeOtherSessions logs the user out of every device but this one
+func (h *Handlers) PostRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID := h.App.Session.GetInt(r.Context(), "userID")
+	if userID == 0 {
+		http.Redirect(w, r, "/users/login", http.StatusSeeOther)
+		return
+	}
+
+	if h.App.UserSessions == nil {
+		h.App.ErrorStatus(w, http.StatusNotFound)
+		return
+	}
+
+	sessions, err := h.App.UserSessions.ListSessions(r.Context(), userID)
+	if err != nil {
+		h.App.ErrorLog.Println(err)
+		h.App.Error500(w, r)
+		return
+	}
+
+	for _, s := range sessions {
+		if !s.Current {
+			h.deleteSessionRememberToken(r, s.ID)
+		}
+	}
+
+	err = h.App.UserSessions.RevokeOtherSessions(r.Context())
+	if err != nil {
+		h.App.ErrorLog.Println(err)
+		h.App.Error500(w, r)
+		return
+	}
+
+	h.App.Session.Put(r.Context(), "flash", "You have been logged out of every other device")
+	http.Redirect(w, r, "/users/sessions", http.StatusSeeOther)
+}
+
+// deleteSessionRememberToken deletes the remember token of the session id, if it has one, so
+// that the device cannot log itself back in
+func (h *Handlers) deleteSessionRememberToken(r *http.Request, id string) {
+	values, err := h.App.UserSessions.SessionValues(r.Context(), id)
+	if err != nil {
+		return
+	}
+
+	if token, ok := values["remember_token"].(string); ok {
+		rt := data.RememberToken{}
+		_ = rt.Delete(token)
+	}
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/justinas/nosurf"
+	"github.com/tschenhau/celeritas/cache"
 )
 
 func (h *Handlers) ShowCachePage(w http.ResponseWriter, r *http.Request) {
@@ -68,10 +70,14 @@ func (h *Handlers) GetFromCache(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fromCache, err := h.App.Cache.Get(userInput.Name)
-	if err != nil {
+	fromCache, err := cache.GetAs[string](h.App.Cache, userInput.Name)
+	if errors.Is(err, cache.ErrCacheMiss) {
 		msg = "Not found in cache!"
 		inCache = false
+	} else if err != nil {
+		h.App.ErrorLog.Println(err)
+		h.App.Error500(w, r)
+		return
 	}
 
 	var resp struct {
@@ -83,7 +89,7 @@ func (h *Handlers) GetFromCache(w http.ResponseWriter, r *http.Request) {
 	if inCache {
 		resp.Error = false
 		resp.Message = "Success"
-		resp.Value = fromCache.(string)
+		resp.Value = fromCache
 	} else {
 		resp.Error = true
 		resp.Message = msg
@@ -5,7 +5,6 @@ import (
 	"myapp/data"
 	"net/http"
 
-	"github.com/CloudyKit/jet/v6"
 	"github.com/tschenhau/celeritas"
 )
 
@@ -40,23 +39,6 @@ func (h *Handlers) JetPage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SessionTest is the handler to demonstrate session functionality
-func (h *Handlers) SessionTest(w http.ResponseWriter, r *http.Request) {
-	myData := "bar"
-
-	h.App.Session.Put(r.Context(), "foo", myData)
-
-	myValue := h.App.Session.GetString(r.Context(), "foo")
-
-	vars := make(jet.VarMap)
-	vars.Set("foo", myValue)
-
-	err := h.App.Render.JetPage(w, r, "sessions", vars, nil)
-	if err != nil {
-		h.App.ErrorLog.Println("error rendering:", err)
-	}
-}
-
 // JSON is the handler to demonstrate json responses
 func (h *Handlers) JSON(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
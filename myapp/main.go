@@ -1,9 +1,11 @@
 package main
 
 import (
+	"log"
 	"myapp/data"
 	"myapp/handlers"
 	"myapp/middleware"
+	"os"
 
 	"github.com/tschenhau/celeritas"
 )
@@ -17,5 +19,18 @@ type application struct {
 
 func main() {
 	c := initApplication()
-	c.App.ListenAndServe()
+
+	// celeritas queue:work runs the application with queue:work as its first argument
+	if len(os.Args) > 1 && os.Args[1] == "queue:work" {
+		err := c.App.Work(os.Args[2:]...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	err := c.App.ListenAndServe()
+	if err != nil {
+		log.Fatal(err)
+	}
 }
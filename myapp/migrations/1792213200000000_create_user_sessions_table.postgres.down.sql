@@ -0,0 +1 @@
+drop table user_sessions
\ No newline at end of file
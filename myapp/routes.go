@@ -18,7 +18,6 @@ func (a *application) routes() *chi.Mux {
 	a.get("/", a.Handlers.Home)
 	a.App.Routes.Get("/go-page", a.Handlers.GoPage)
 	a.App.Routes.Get("/jet-page", a.Handlers.JetPage)
-	a.App.Routes.Get("/sessions", a.Handlers.SessionTest)
 
 	a.App.Routes.Get("/users/login", a.Handlers.UserLogin)
 	a.post("/users/login", a.Handlers.PostUserLogin)
@@ -27,6 +26,9 @@ func (a *application) routes() *chi.Mux {
 	a.post("/users/forgot-password", a.Handlers.PostForgot)
 	a.get("/users/reset-password", a.Handlers.ResetPasswordForm)
 	a.post("/users/reset-password", a.Handlers.PostResetPassword)
+	a.get("/users/sessions", a.Handlers.Sessions)
+	a.post("/users/sessions/revoke", a.Handlers.PostRevokeSession)
+	a.post("/users/sessions/revoke-others", a.Handlers.PostRevokeOtherSessions)
 
 	a.App.Routes.Get("/form", a.Handlers.Form)
 	a.App.Routes.Post("/form", a.Handlers.PostForm)
@@ -53,8 +55,7 @@ func (a *application) routes() *chi.Mux {
 			Data:        nil,
 		}
 
-		a.App.Mail.Jobs <- msg
-		res := <-a.App.Mail.Results
+		res := <-a.App.Mail.SendAsync(r.Context(), msg)
 		if res.Error != nil {
 			a.App.ErrorLog.Println(res.Error)
 		}